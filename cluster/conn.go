@@ -0,0 +1,440 @@
+// Copyright © 2022 Meroxa, Inc. & Gophers Lab Technologies Pvt. Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// maxRedirects is the number of MOVED and ASK redirections followed for a single command
+const maxRedirects = 5
+
+var (
+	errClosed   = errors.New("cluster connection is closed")
+	errNoSlots  = errors.New("no slots are assigned in the cluster")
+	errPipeline = errors.New("the pipelined commands of a cluster connection must target the same node")
+)
+
+// Dialer creates a connection to the cluster node at the address
+type Dialer func(ctx context.Context, address string) (redis.Conn, error)
+
+// Conn is a redis.Conn routing each command to the master owning the slot of its key, over one connection per node.
+// The MOVED and ASK redirections are followed, and the topology is discovered again after a MOVED redirection,
+// as it means the slots were resharded. The commands without key are sent to the master of the first slot.
+//
+// The commands sent with Send are pipelined on a single node, until all their replies were received. The
+// connection isn't safe for concurrent use, except for Close, the same way as the connections of redigo.
+type Conn struct {
+	dial   Dialer
+	seeds  []string
+	useTLS bool
+
+	mux *sync.Mutex
+	// slots holds the address of the master owning each slot, nodes the connection to each of them
+	slots []string
+	nodes map[string]redis.Conn
+	// pipe is the connection of the pipelined commands, pending the number of replies not received yet
+	pipe    redis.Conn
+	pending int
+	closed  bool
+}
+
+// NewConn discovers the topology of the cluster from the first reachable seed, with CLUSTER SHARDS or with
+// CLUSTER SLOTS when connecting to a redis older than 7.0. useTLS selects the TLS port of the nodes.
+func NewConn(ctx context.Context, seeds []string, useTLS bool, dial Dialer) (*Conn, error) {
+	c := &Conn{
+		dial:   dial,
+		seeds:  seeds,
+		useTLS: useTLS,
+		mux:    &sync.Mutex{},
+		nodes:  make(map[string]redis.Conn),
+	}
+	if err := c.refresh(ctx); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// refresh discovers the topology from the known masters or, when none of them is reachable, from the seeds
+func (c *Conn) refresh(ctx context.Context) error {
+	c.mux.Lock()
+	addresses := make([]string, 0, len(c.nodes)+len(c.seeds))
+	for address := range c.nodes {
+		addresses = append(addresses, address)
+	}
+	c.mux.Unlock()
+	addresses = append(addresses, c.seeds...)
+
+	errs := make([]error, 0, len(addresses))
+	for _, address := range addresses {
+		conn, err := c.node(ctx, address)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		slots, err := topology(conn, c.useTLS)
+		if err != nil {
+			c.drop(address)
+			errs = append(errs, fmt.Errorf("node(%s): %w", address, err))
+			continue
+		}
+		c.setSlots(slots)
+		return nil
+	}
+	return fmt.Errorf("failed to discover the cluster topology: %w", errors.Join(errs...))
+}
+
+// setSlots replaces the slots, closing the connections to the nodes which aren't masters anymore
+func (c *Conn) setSlots(slots []string) {
+	masters := make(map[string]bool)
+	for _, address := range slots {
+		masters[address] = true
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.slots = slots
+	for address, conn := range c.nodes {
+		if !masters[address] && conn != c.pipe {
+			_ = conn.Close()
+			delete(c.nodes, address)
+		}
+	}
+}
+
+// node returns the connection to the node at the address, dialing it when needed
+func (c *Conn) node(ctx context.Context, address string) (redis.Conn, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.closed {
+		return nil, errClosed
+	}
+	if conn, ok := c.nodes[address]; ok {
+		if conn.Err() == nil {
+			return conn, nil
+		}
+		// the connection is broken, e.g. the node restarted, the replies pending on it are lost
+		_ = conn.Close()
+		delete(c.nodes, address)
+		if conn == c.pipe {
+			c.pipe, c.pending = nil, 0
+		}
+	}
+	conn, err := c.dial(ctx, address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to node(%s): %w", address, err)
+	}
+	c.nodes[address] = conn
+	return conn, nil
+}
+
+// drop closes the connection to the node at the address, it is dialed again when needed
+func (c *Conn) drop(address string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if conn, ok := c.nodes[address]; ok {
+		_ = conn.Close()
+		delete(c.nodes, address)
+	}
+}
+
+// address returns the address of the master owning the slot of the key, or of the first slot for the commands
+// without key
+func (c *Conn) address(key string, hasKey bool) (string, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.closed {
+		return "", errClosed
+	}
+	slot := 0
+	if hasKey {
+		slot = Slot(key)
+	}
+	if address := c.slots[slot]; address != "" {
+		return address, nil
+	}
+	for _, address := range c.slots {
+		if address != "" {
+			return address, nil
+		}
+	}
+	return "", errNoSlots
+}
+
+// Do runs the command on the master owning the slot of its key, following the redirections
+func (c *Conn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	return c.DoContext(context.Background(), cmd, args...)
+}
+
+// DoContext runs the command like Do, the context is passed to the nodes which support it, so that the command
+// is canceled with the context
+func (c *Conn) DoContext(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	if cmd == "" {
+		// redigo flushes the pipeline and receives all the pending replies when the command is empty
+		return c.receiveAll(ctx)
+	}
+
+	key, hasKey := commandKey(cmd, args)
+	address, err := c.address(key, hasKey)
+	if err != nil {
+		return nil, err
+	}
+
+	asking := false
+	for redirects := 0; ; redirects++ {
+		conn, err := c.node(ctx, address)
+		if err != nil {
+			return nil, err
+		}
+		if asking {
+			if err := conn.Send("ASKING"); err != nil {
+				return nil, err
+			}
+		}
+		reply, err := doContext(ctx, conn, cmd, args...)
+		if conn == c.pipeConn() {
+			// the pending replies of the pipeline were received by Do
+			c.resetPipe()
+		}
+
+		redirect, target, ok := parseRedirect(err)
+		if !ok {
+			if err != nil && conn.Err() != nil {
+				// the node is unreachable, which can be due to a failover, discover the new master
+				c.drop(address)
+				_ = c.refresh(ctx)
+			}
+			return reply, err
+		}
+		if redirects >= maxRedirects {
+			return nil, fmt.Errorf("too many cluster redirections, last one: %w", err)
+		}
+		if strings.HasPrefix(target, ":") {
+			// the node doesn't know its own host, which is the one of the redirecting node
+			host, _, _ := net.SplitHostPort(address)
+			target = net.JoinHostPort(host, target[1:])
+		}
+		if redirect == "MOVED" {
+			// the slot was moved to another master, the other slots may have moved as well
+			if err := c.refresh(ctx); err != nil {
+				return nil, err
+			}
+		}
+		address, asking = target, redirect == "ASK"
+	}
+}
+
+// Send queues the command on the master owning the slot of its key, the commands without key are queued on the node
+// of the pending commands. The commands queued until all the replies were received must target the same node.
+func (c *Conn) Send(cmd string, args ...interface{}) error {
+	key, hasKey := commandKey(cmd, args)
+	pipe := c.pipeConn()
+	conn := pipe
+	if hasKey || pipe == nil {
+		address, err := c.address(key, hasKey)
+		if err != nil {
+			return err
+		}
+		if conn, err = c.node(context.Background(), address); err != nil {
+			return err
+		}
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.pipe != nil && c.pipe != conn && c.pending > 0 {
+		return errPipeline
+	}
+	if err := conn.Send(cmd, args...); err != nil {
+		return err
+	}
+	c.pipe = conn
+	c.pending++
+	return nil
+}
+
+// Flush flushes the pipelined commands to the node
+func (c *Conn) Flush() error {
+	pipe := c.pipeConn()
+	if pipe == nil {
+		return nil
+	}
+	return pipe.Flush()
+}
+
+// Receive receives a single reply from the node of the pipelined commands. The node is kept once all the replies
+// were received, so that the pushed messages of the subscribed channels can be received as well.
+func (c *Conn) Receive() (interface{}, error) {
+	return c.ReceiveContext(context.Background())
+}
+
+// ReceiveContext receives a single reply like Receive, the context is passed to the node when it supports it
+func (c *Conn) ReceiveContext(ctx context.Context) (interface{}, error) {
+	pipe := c.pipeConn()
+	if pipe == nil {
+		return nil, errors.New("no command was sent to the cluster connection")
+	}
+	var reply interface{}
+	var err error
+	if cwc, ok := pipe.(redis.ConnWithContext); ok {
+		reply, err = cwc.ReceiveContext(ctx)
+	} else {
+		reply, err = pipe.Receive()
+	}
+
+	c.mux.Lock()
+	if c.pending > 0 {
+		c.pending--
+	}
+	c.mux.Unlock()
+	return reply, err
+}
+
+// receiveAll flushes the pipelined commands and returns all their replies
+func (c *Conn) receiveAll(ctx context.Context) (interface{}, error) {
+	pipe := c.pipeConn()
+	if pipe == nil {
+		return nil, nil
+	}
+	defer c.resetPipe()
+	return doContext(ctx, pipe, "")
+}
+
+// doContext runs the command on the node with the context, when the connection supports it
+func doContext(ctx context.Context, conn redis.Conn, cmd string, args ...interface{}) (interface{}, error) {
+	if cwc, ok := conn.(redis.ConnWithContext); ok {
+		return cwc.DoContext(ctx, cmd, args...)
+	}
+	return conn.Do(cmd, args...)
+}
+
+func (c *Conn) pipeConn() redis.Conn {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.pipe
+}
+
+func (c *Conn) resetPipe() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.pending = 0
+}
+
+// Err returns a non-nil value when the connection is closed, or when the connection to a node is broken. The error of
+// the node of the pipelined commands is returned first, as their remaining replies are lost. A broken connection is
+// dialed again the next time its node is used.
+func (c *Conn) Err() error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.closed {
+		return errClosed
+	}
+
+	addresses := make([]string, 0, len(c.nodes))
+	for address := range c.nodes {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	var nodeErr error
+	for _, address := range addresses {
+		conn := c.nodes[address]
+		err := conn.Err()
+		if err == nil {
+			continue
+		}
+		err = fmt.Errorf("node(%s): %w", address, err)
+		if conn == c.pipe {
+			return err
+		}
+		if nodeErr == nil {
+			nodeErr = err
+		}
+	}
+	return nodeErr
+}
+
+// Close closes the connections to all the nodes
+func (c *Conn) Close() error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.closed = true
+	errs := make([]error, 0, len(c.nodes))
+	for address, conn := range c.nodes {
+		if err := conn.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("node(%s): %w", address, err))
+		}
+		delete(c.nodes, address)
+	}
+	return errors.Join(errs...)
+}
+
+// ForEachMaster runs fn on the connection to each master, in the order of their slots, it is used for the commands
+// scoped to a node, like SCAN
+func (c *Conn) ForEachMaster(fn func(address string, conn redis.Conn) error) error {
+	c.mux.Lock()
+	addresses := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, address := range c.slots {
+		if address != "" && !seen[address] {
+			seen[address] = true
+			addresses = append(addresses, address)
+		}
+	}
+	c.mux.Unlock()
+
+	for _, address := range addresses {
+		conn, err := c.node(context.Background(), address)
+		if err != nil {
+			return err
+		}
+		if err := fn(address, conn); err != nil {
+			return fmt.Errorf("node(%s): %w", address, err)
+		}
+	}
+	return nil
+}
+
+// IsRedirect returns whether the error is a MOVED or ASK redirection, which Do follows but the pipelined commands
+// receive as their reply
+func IsRedirect(err error) bool {
+	_, _, ok := parseRedirect(err)
+	return ok
+}
+
+// parseRedirect parses the MOVED and ASK errors, formatted as "MOVED <slot> <address>"
+func parseRedirect(err error) (string, string, bool) {
+	var redisErr redis.Error
+	if !errors.As(err, &redisErr) {
+		return "", "", false
+	}
+	parts := strings.Fields(string(redisErr))
+	if len(parts) != 3 || (parts[0] != "MOVED" && parts[0] != "ASK") {
+		return "", "", false
+	}
+	if _, err := strconv.Atoi(parts[1]); err != nil {
+		return "", "", false
+	}
+	return parts[0], parts[2], true
+}
@@ -0,0 +1,358 @@
+// Copyright © 2022 Meroxa, Inc. & Gophers Lab Technologies Pvt. Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the slots of the keys used in the tests, with the slots split in half between the two masters
+const (
+	keyFirst  = "bar" // slot 5061
+	keySecond = "foo" // slot 12182
+)
+
+// testCluster runs two masters, the first one owning the slots below split and the second one the others.
+// They reply to CLUSTER SHARDS with the topology and redirect the keys they don't own with MOVED.
+type testCluster struct {
+	masters []*miniredis.Miniredis
+
+	mux   *sync.Mutex
+	split int
+	// ask is the key the second master redirects to the first one with ASK, as if its slot was being migrated
+	ask string
+	// asking holds the connections the first master received ASKING from
+	asking map[*server.Peer]bool
+	// noShards makes the masters reply to CLUSTER SHARDS with an error, like the versions older than 7.0
+	noShards bool
+}
+
+func newTestCluster(t *testing.T) *testCluster {
+	t.Helper()
+	tc := &testCluster{
+		masters: []*miniredis.Miniredis{miniredis.RunT(t), miniredis.RunT(t)},
+		mux:     &sync.Mutex{},
+		split:   SlotCount / 2,
+		asking:  make(map[*server.Peer]bool),
+	}
+	for idx, mr := range tc.masters {
+		mr.Server().SetPreHook(func(c *server.Peer, cmd string, args ...string) bool {
+			return tc.hook(idx, c, cmd, args)
+		})
+	}
+	return tc
+}
+
+func (tc *testCluster) hook(idx int, c *server.Peer, cmd string, args []string) bool {
+	tc.mux.Lock()
+	defer tc.mux.Unlock()
+	switch {
+	case cmd == "CLUSTER" && len(args) == 1 && args[0] == "SHARDS":
+		if tc.noShards {
+			c.WriteError("ERR unknown subcommand 'SHARDS'")
+			return true
+		}
+		tc.writeShards(c)
+		return true
+	case cmd == "ASKING":
+		tc.asking[c] = true
+		c.WriteOK()
+		return true
+	}
+
+	iargs := make([]interface{}, len(args))
+	for i, arg := range args {
+		iargs[i] = arg
+	}
+	key, ok := commandKey(cmd, iargs)
+	if !ok {
+		return false
+	}
+	slot := Slot(key)
+	if idx == 1 && key == tc.ask {
+		c.WriteError(fmt.Sprintf("ASK %d %s", slot, tc.masters[0].Addr()))
+		return true
+	}
+	if idx == 0 && tc.asking[c] {
+		delete(tc.asking, c)
+		return false
+	}
+	if owner := tc.owner(slot); owner != idx {
+		c.WriteError(fmt.Sprintf("MOVED %d %s", slot, tc.masters[owner].Addr()))
+		return true
+	}
+	return false
+}
+
+func (tc *testCluster) owner(slot int) int {
+	if slot < tc.split {
+		return 0
+	}
+	return 1
+}
+
+// writeShards writes the reply of CLUSTER SHARDS, with a replica in each shard
+func (tc *testCluster) writeShards(c *server.Peer) {
+	ranges := [][2]int{{0, tc.split - 1}, {tc.split, SlotCount - 1}}
+	c.WriteLen(len(tc.masters))
+	for idx, mr := range tc.masters {
+		c.WriteMapLen(2)
+		c.WriteBulk("slots")
+		if ranges[idx][0] > ranges[idx][1] {
+			c.WriteLen(0)
+		} else {
+			c.WriteLen(2)
+			c.WriteInt(ranges[idx][0])
+			c.WriteInt(ranges[idx][1])
+		}
+		c.WriteBulk("nodes")
+		c.WriteLen(2)
+		for _, role := range []string{"replica", "master"} {
+			c.WriteMapLen(5)
+			c.WriteBulk("ip")
+			c.WriteBulk(mr.Host())
+			c.WriteBulk("port")
+			c.WriteBulk(mr.Port())
+			c.WriteBulk("endpoint")
+			c.WriteBulk(mr.Host())
+			c.WriteBulk("role")
+			c.WriteBulk(role)
+			c.WriteBulk("health")
+			c.WriteBulk("online")
+		}
+	}
+}
+
+func (tc *testCluster) update(fn func()) {
+	tc.mux.Lock()
+	defer tc.mux.Unlock()
+	fn()
+}
+
+func (tc *testCluster) conn(t *testing.T) *Conn {
+	t.Helper()
+	dial := func(ctx context.Context, address string) (redis.Conn, error) {
+		return redis.DialContext(ctx, "tcp", address)
+	}
+	conn, err := NewConn(context.Background(), []string{"127.0.0.1:1", tc.masters[1].Addr()}, false, dial)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestConn_Routing(t *testing.T) {
+	tc := newTestCluster(t)
+	conn := tc.conn(t)
+
+	for _, key := range []string{keyFirst, keySecond} {
+		_, err := conn.Do("XADD", key, "*", "key", key)
+		require.NoError(t, err)
+	}
+	for idx, key := range []string{keyFirst, keySecond} {
+		entries, err := tc.masters[idx].Stream(key)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+
+		resp, err := redis.Values(conn.Do("XREAD", "COUNT", 10, "STREAMS", key, "0-0"))
+		assert.NoError(t, err)
+		assert.Len(t, resp, 1)
+		length, err := redis.Int(conn.Do("XLEN", key))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, length)
+	}
+
+	visited := make([]string, 0)
+	err := conn.ForEachMaster(func(_ string, node redis.Conn) error {
+		keys, err := redis.Values(node.Do("SCAN", 0, "MATCH", "*"))
+		if err != nil {
+			return err
+		}
+		matched, err := redis.Strings(keys[1], nil)
+		visited = append(visited, matched...)
+		return err
+	})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{keyFirst, keySecond}, visited)
+}
+
+func TestConn_Context(t *testing.T) {
+	tc := newTestCluster(t)
+	conn := tc.conn(t)
+
+	_, err := redis.DoContext(conn, context.Background(), "SET", keySecond, "value")
+	assert.NoError(t, err)
+	assert.NoError(t, conn.Send("GET", keySecond))
+	assert.NoError(t, conn.Flush())
+	reply, err := redis.String(redis.ReceiveContext(conn, context.Background()))
+	assert.NoError(t, err)
+	assert.Equal(t, "value", reply)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = redis.DoContext(conn, ctx, "GET", keySecond)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestConn_Moved(t *testing.T) {
+	tc := newTestCluster(t)
+	conn := tc.conn(t)
+
+	// all the slots are resharded to the first master
+	tc.update(func() { tc.split = SlotCount })
+	_, err := conn.Do("SET", keySecond, "value")
+	assert.NoError(t, err)
+	value, err := tc.masters[0].Get(keySecond)
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+
+	// the topology was discovered again
+	address, err := conn.address(keySecond, true)
+	assert.NoError(t, err)
+	assert.Equal(t, tc.masters[0].Addr(), address)
+}
+
+func TestConn_Ask(t *testing.T) {
+	tc := newTestCluster(t)
+	conn := tc.conn(t)
+
+	tc.update(func() { tc.ask = keySecond })
+	_, err := conn.Do("SET", keySecond, "value")
+	assert.NoError(t, err)
+	value, err := tc.masters[0].Get(keySecond)
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+
+	// ASK only redirects the command, the slot isn't moved yet
+	address, err := conn.address(keySecond, true)
+	assert.NoError(t, err)
+	assert.Equal(t, tc.masters[1].Addr(), address)
+}
+
+func TestConn_ClusterSlots(t *testing.T) {
+	tc := newTestCluster(t)
+	tc.update(func() { tc.noShards = true })
+	dial := func(ctx context.Context, address string) (redis.Conn, error) {
+		return redis.DialContext(ctx, "tcp", address)
+	}
+	// miniredis replies to CLUSTER SLOTS with all the slots on itself
+	conn, err := NewConn(context.Background(), []string{tc.masters[0].Addr()}, false, dial)
+	require.NoError(t, err)
+	defer conn.Close()
+	for _, key := range []string{keyFirst, keySecond} {
+		address, err := conn.address(key, true)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.masters[0].Addr(), address)
+	}
+}
+
+func TestConn_Pipeline(t *testing.T) {
+	tc := newTestCluster(t)
+	conn := tc.conn(t)
+
+	assert.NoError(t, conn.Send("SET", keyFirst, "value"))
+	assert.NoError(t, conn.Send("GET", keyFirst))
+	assert.ErrorIs(t, conn.Send("SET", keySecond, "value"), errPipeline)
+	assert.NoError(t, conn.Flush())
+	reply, err := redis.String(conn.Receive())
+	assert.NoError(t, err)
+	assert.Equal(t, "OK", reply)
+	reply, err = redis.String(conn.Receive())
+	assert.NoError(t, err)
+	assert.Equal(t, "value", reply)
+
+	// once all the replies were received, the commands can be pipelined to another node
+	assert.NoError(t, conn.Send("SET", keySecond, "value"))
+	replies, err := redis.Values(conn.Do(""))
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"OK"}, replies)
+
+	// the pipelined commands receive the redirections, the command is then run with Do which follows it
+	// and discovers the topology again, so that the next commands are pipelined to the new owner of the slot
+	tc.update(func() { tc.split = SlotCount })
+	assert.NoError(t, conn.Send("SET", keySecond, "other"))
+	assert.NoError(t, conn.Flush())
+	_, err = conn.Receive()
+	assert.True(t, IsRedirect(err))
+	_, err = conn.Do("SET", keySecond, "other")
+	assert.NoError(t, err)
+	assert.NoError(t, conn.Send("GET", keySecond))
+	values, err := redis.Strings(conn.Do(""))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"other"}, values)
+}
+
+func TestConn_Err(t *testing.T) {
+	tc := newTestCluster(t)
+	conn := tc.conn(t)
+	_, err := conn.Do("SET", keyFirst, "value")
+	assert.NoError(t, err)
+	assert.NoError(t, conn.Err())
+
+	// the node of the pipelined commands is lost before their replies are received
+	addr := tc.masters[1].Addr()
+	assert.NoError(t, conn.Send("GET", keySecond))
+	tc.masters[1].Close()
+	_ = conn.Flush()
+	_, err = conn.Receive()
+	assert.Error(t, err)
+	assert.ErrorContains(t, conn.Err(), addr)
+
+	// the broken connection is dialed again once the node is back
+	require.NoError(t, tc.masters[1].Restart())
+	tc.masters[1].Server().SetPreHook(func(c *server.Peer, cmd string, args ...string) bool {
+		return tc.hook(1, c, cmd, args)
+	})
+	reply, err := redis.String(conn.Do("SET", keySecond, "value"))
+	assert.NoError(t, err)
+	assert.Equal(t, "OK", reply)
+	assert.NoError(t, conn.Err())
+
+	assert.NoError(t, conn.Close())
+	assert.ErrorIs(t, conn.Err(), errClosed)
+}
+
+func TestCommandKey(t *testing.T) {
+	tests := []struct {
+		cmd    string
+		args   []interface{}
+		key    string
+		hasKey bool
+	}{
+		{cmd: "XADD", args: []interface{}{"mystream", "*", "a", "b"}, key: "mystream", hasKey: true},
+		{cmd: "XREADGROUP", args: []interface{}{"GROUP", "g", "c", "COUNT", 10, "STREAMS", "mystream", ">"}, key: "mystream", hasKey: true},
+		{cmd: "XINFO", args: []interface{}{"STREAM", []byte("mystream")}, key: "mystream", hasKey: true},
+		{cmd: "EVAL", args: []interface{}{"return 1", 1, "mykey"}, key: "mykey", hasKey: true},
+		{cmd: "EVAL", args: []interface{}{"return 1", 0}, hasKey: false},
+		{cmd: "SUBSCRIBE", args: []interface{}{"channel"}, hasKey: false},
+		{cmd: "SSUBSCRIBE", args: []interface{}{"channel"}, key: "channel", hasKey: true},
+		{cmd: "PING", hasKey: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cmd, func(t *testing.T) {
+			key, hasKey := commandKey(tt.cmd, tt.args)
+			assert.Equal(t, tt.key, key)
+			assert.Equal(t, tt.hasKey, hasKey)
+		})
+	}
+}
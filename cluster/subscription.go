@@ -0,0 +1,128 @@
+// Copyright © 2022 Meroxa, Inc. & Gophers Lab Technologies Pvt. Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+var errSubscriptionDo = errors.New("the subscription connection of a cluster only supports Send, Flush and Receive")
+
+// SubscriptionConn is a redis.Conn subscribed on every master of the cluster, the commands are sent to all of them
+// and their replies and pushed messages are received in the order they arrive. It is used for the keyspace
+// notifications, which are only published by the master owning the key. The masters are the ones of the topology
+// when the connection is created, it is to be created again when a master is lost.
+//
+// Only Send, Flush, Receive, Err and Close are supported, which are the methods used by redis.PubSubConn. Each
+// command gets a reply from every master.
+type SubscriptionConn struct {
+	conn    *Conn
+	masters []redis.Conn
+	replies chan subscriptionReply
+	done    chan struct{}
+	once    *sync.Once
+}
+
+type subscriptionReply struct {
+	reply interface{}
+	err   error
+}
+
+// NewSubscriptionConn takes over the connections of conn to the masters, conn isn't to be used anymore and is closed
+// with the subscription connection
+func NewSubscriptionConn(conn *Conn) (*SubscriptionConn, error) {
+	c := &SubscriptionConn{
+		conn:    conn,
+		replies: make(chan subscriptionReply),
+		done:    make(chan struct{}),
+		once:    &sync.Once{},
+	}
+	err := conn.ForEachMaster(func(_ string, node redis.Conn) error {
+		c.masters = append(c.masters, node)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range c.masters {
+		// redigo supports a concurrent caller of Receive alongside the one of Send and Flush
+		go c.receive(node)
+	}
+	return c, nil
+}
+
+// receive is the go routine receiving the replies of a master, until its connection is broken or closed
+func (c *SubscriptionConn) receive(node redis.Conn) {
+	for {
+		reply, err := node.Receive()
+		select {
+		case c.replies <- subscriptionReply{reply: reply, err: err}:
+		case <-c.done:
+			return
+		}
+		if err != nil && node.Err() != nil {
+			return
+		}
+	}
+}
+
+// Do isn't supported, as every master replies to the commands
+func (c *SubscriptionConn) Do(string, ...interface{}) (interface{}, error) {
+	return nil, errSubscriptionDo
+}
+
+// Send queues the command on every master
+func (c *SubscriptionConn) Send(cmd string, args ...interface{}) error {
+	for _, node := range c.masters {
+		if err := node.Send(cmd, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush flushes the queued commands to every master
+func (c *SubscriptionConn) Flush() error {
+	for _, node := range c.masters {
+		if err := node.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Receive receives the next reply or pushed message of any master
+func (c *SubscriptionConn) Receive() (interface{}, error) {
+	select {
+	case r := <-c.replies:
+		return r.reply, r.err
+	case <-c.done:
+		return nil, errClosed
+	}
+}
+
+// Err returns a non-nil value when the connection is closed or the connection to a master is broken
+func (c *SubscriptionConn) Err() error {
+	return c.conn.Err()
+}
+
+// Close closes the connections to the masters
+func (c *SubscriptionConn) Close() error {
+	c.once.Do(func() { close(c.done) })
+	return c.conn.Close()
+}
@@ -0,0 +1,60 @@
+// Copyright © 2022 Meroxa, Inc. & Gophers Lab Technologies Pvt. Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriptionConn(t *testing.T) {
+	tc := newTestCluster(t)
+	sub, err := NewSubscriptionConn(tc.conn(t))
+	require.NoError(t, err)
+	psc := redis.PubSubConn{Conn: sub}
+	defer psc.Close()
+
+	// each master confirms the subscription
+	require.NoError(t, psc.PSubscribe("__keyspace@0__:*"))
+	for range tc.masters {
+		reply, ok := psc.Receive().(redis.Subscription)
+		assert.True(t, ok)
+		assert.Equal(t, 1, reply.Count)
+	}
+	for idx, mr := range tc.masters {
+		assert.Equal(t, 1, mr.PubSubNumPat(), "master %d", idx)
+	}
+
+	// the notifications are published by the master owning the key
+	tc.masters[0].Publish("__keyspace@0__:"+keyFirst, "set")
+	tc.masters[1].Publish("__keyspace@0__:"+keySecond, "del")
+	channels := make([]string, 0)
+	for range tc.masters {
+		msg, ok := psc.Receive().(redis.Message)
+		require.True(t, ok)
+		channels = append(channels, msg.Channel)
+	}
+	assert.ElementsMatch(t, []string{"__keyspace@0__:" + keyFirst, "__keyspace@0__:" + keySecond}, channels)
+
+	_, err = sub.Do("GET", keyFirst)
+	assert.ErrorIs(t, err, errSubscriptionDo)
+
+	assert.NoError(t, psc.Close())
+	_, err = sub.Receive()
+	assert.ErrorIs(t, err, errClosed)
+}
@@ -0,0 +1,220 @@
+// Copyright © 2022 Meroxa, Inc. & Gophers Lab Technologies Pvt. Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// topology returns the address of the master owning each slot, using CLUSTER SHARDS and falling back to
+// CLUSTER SLOTS for the versions of redis older than 7.0
+func topology(conn redis.Conn, useTLS bool) ([]string, error) {
+	shards, err := redis.Values(conn.Do("CLUSTER", "SHARDS"))
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		return clusterSlots(conn)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching the cluster shards: %w", err)
+	}
+	return clusterShards(shards, useTLS)
+}
+
+// clusterShards parses the reply of CLUSTER SHARDS, which holds the slot ranges and the nodes of each shard
+func clusterShards(shards []interface{}, useTLS bool) ([]string, error) {
+	slots := make([]string, SlotCount)
+	for _, shard := range shards {
+		fields, err := redis.Values(shard, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cluster shard: %w", err)
+		}
+		shardMap, err := replyMap(fields)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cluster shard: %w", err)
+		}
+		ranges, err := redis.Ints(shardMap["slots"], nil)
+		if err != nil || len(ranges)%2 != 0 {
+			return nil, fmt.Errorf("invalid slots of cluster shard: %v", shardMap["slots"])
+		}
+		nodes, err := redis.Values(shardMap["nodes"], nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid nodes of cluster shard: %w", err)
+		}
+
+		address, err := shardMaster(nodes, useTLS)
+		if err != nil {
+			return nil, err
+		}
+		if address == "" {
+			// the shard has no healthy master, e.g. during a failover, its slots are redirected
+			continue
+		}
+		for i := 0; i < len(ranges); i += 2 {
+			if err := assign(slots, ranges[i], ranges[i+1], address); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return slots, nil
+}
+
+// shardMaster returns the address of the online master among the nodes of a shard, or "" if there is none
+func shardMaster(nodes []interface{}, useTLS bool) (string, error) {
+	for _, node := range nodes {
+		fields, err := redis.Values(node, nil)
+		if err != nil {
+			return "", fmt.Errorf("invalid cluster node: %w", err)
+		}
+		nodeMap, err := replyMap(fields)
+		if err != nil {
+			return "", fmt.Errorf("invalid cluster node: %w", err)
+		}
+		role, _ := redis.String(nodeMap["role"], nil)
+		health, _ := redis.String(nodeMap["health"], nil)
+		if role != "master" || (health != "" && health != "online") {
+			continue
+		}
+
+		host, _ := redis.String(nodeMap["endpoint"], nil)
+		if host == "" || host == "?" {
+			if host, err = redis.String(nodeMap["ip"], nil); err != nil {
+				return "", fmt.Errorf("invalid ip of cluster node: %w", err)
+			}
+		}
+		portKey := "port"
+		if _, ok := nodeMap["tls-port"]; ok && useTLS {
+			portKey = "tls-port"
+		}
+		port, err := redis.Int(nodeMap[portKey], nil)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s of cluster node: %w", portKey, err)
+		}
+		return net.JoinHostPort(host, strconv.Itoa(port)), nil
+	}
+	return "", nil
+}
+
+// clusterSlots returns the address of the master owning each slot using CLUSTER SLOTS, which replies with the
+// slot ranges followed by their master and replicas
+func clusterSlots(conn redis.Conn) ([]string, error) {
+	ranges, err := redis.Values(conn.Do("CLUSTER", "SLOTS"))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching the cluster slots: %w", err)
+	}
+	slots := make([]string, SlotCount)
+	for _, r := range ranges {
+		fields, err := redis.Values(r, nil)
+		if err != nil || len(fields) < 3 {
+			return nil, fmt.Errorf("invalid cluster slot range: %v", r)
+		}
+		start, err := redis.Int(fields[0], nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cluster slot range start: %w", err)
+		}
+		end, err := redis.Int(fields[1], nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cluster slot range end: %w", err)
+		}
+		master, err := redis.Values(fields[2], nil)
+		if err != nil || len(master) < 2 {
+			return nil, fmt.Errorf("invalid cluster slot master: %v", fields[2])
+		}
+		host, err := redis.String(master[0], nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cluster slot master host: %w", err)
+		}
+		port, err := redis.Int(master[1], nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cluster slot master port: %w", err)
+		}
+		if err := assign(slots, start, end, net.JoinHostPort(host, strconv.Itoa(port))); err != nil {
+			return nil, err
+		}
+	}
+	return slots, nil
+}
+
+// assign sets the address of the slots from start to end, inclusive
+func assign(slots []string, start, end int, address string) error {
+	if start < 0 || end >= SlotCount || start > end {
+		return fmt.Errorf("invalid cluster slot range %d-%d", start, end)
+	}
+	for slot := start; slot <= end; slot++ {
+		slots[slot] = address
+	}
+	return nil
+}
+
+// replyMap converts the flat list of names and values redis replies with for maps
+func replyMap(fields []interface{}) (map[string]interface{}, error) {
+	if len(fields)%2 != 0 {
+		return nil, fmt.Errorf("expected name and value pairs, got %d elements", len(fields))
+	}
+	m := make(map[string]interface{}, len(fields)/2)
+	for i := 0; i < len(fields); i += 2 {
+		name, err := redis.String(fields[i], nil)
+		if err != nil {
+			return nil, err
+		}
+		m[name] = fields[i+1]
+	}
+	return m, nil
+}
+
+// commandKey returns the key the command is routed with, the commands without key return false
+func commandKey(cmd string, args []interface{}) (string, bool) {
+	keyIndex := 0
+	switch strings.ToUpper(cmd) {
+	case "PING", "ECHO", "AUTH", "SELECT", "INFO", "CONFIG", "CLUSTER", "ROLE", "SCAN", "KEYS", "DBSIZE",
+		"MULTI", "EXEC", "DISCARD", "WATCH", "UNWATCH", "ASKING", "READONLY", "READWRITE", "SCRIPT", "FUNCTION",
+		"PUBLISH", "SUBSCRIBE", "UNSUBSCRIBE", "PSUBSCRIBE", "PUNSUBSCRIBE", "CLIENT", "HELLO", "QUIT":
+		return "", false
+	case "XINFO", "XGROUP", "OBJECT", "MEMORY":
+		// the subcommand comes first
+		keyIndex = 1
+	case "EVAL", "EVALSHA", "FCALL":
+		// the script and the number of keys come first
+		if len(args) < 3 || fmt.Sprint(args[1]) == "0" {
+			return "", false
+		}
+		keyIndex = 2
+	case "XREAD", "XREADGROUP":
+		// the keys follow the STREAMS option
+		keyIndex = -1
+		for i, arg := range args {
+			if s, ok := arg.(string); ok && strings.EqualFold(s, "STREAMS") {
+				keyIndex = i + 1
+				break
+			}
+		}
+	}
+	if keyIndex < 0 || keyIndex >= len(args) {
+		return "", false
+	}
+	switch key := args[keyIndex].(type) {
+	case string:
+		return key, true
+	case []byte:
+		return string(key), true
+	default:
+		return fmt.Sprint(key), true
+	}
+}
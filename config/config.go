@@ -17,25 +17,121 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/conduitio-labs/conduit-connector-redis/cluster"
 )
 
 const (
-	KeyHost          = "redis.host"
-	KeyPort          = "redis.port"
-	KeyRedisKey      = "redis.key"
-	KeyDatabase      = "redis.database"
-	KeyUsername      = "redis.username"
-	KeyPassword      = "redis.password"
-	KeyMode          = "mode"
-	KeyPollingPeriod = "pollingPeriod"
+	KeyHost              = "redis.host"
+	KeyPort              = "redis.port"
+	KeyRedisKey          = "redis.key"
+	KeyDatabase          = "redis.database"
+	KeyUsername          = "redis.username"
+	KeyPassword          = "redis.password"
+	KeyMode              = "mode"
+	KeyPollingPeriod     = "pollingPeriod"
+	KeyConsumerGroup     = "consumerGroup"
+	KeyConsumerName      = "consumerName"
+	KeyClaimMinIdle      = "claimMinIdleTime"
+	KeyMaxDeliveries     = "maxDeliveries"
+	KeyDeadLetterKey     = "deadLetterKey"
+	KeyNotifyEvents      = "notifyKeyspaceEvents"
+	KeyProcessingKey     = "processingKey"
+	KeyBlockTimeout      = "blockTimeout"
+	KeyStartFrom         = "startFrom"
+	KeyEndAt             = "endAt"
+	KeyOnEnd             = "onEnd"
+	KeyGapPolicy         = "gapPolicy"
+	KeyPayloadFormat     = "payloadFormat"
+	KeyFieldTypes        = "fieldTypes"
+	KeyKeyField          = "keyField"
+	KeyOperationField    = "operationField"
+	KeyMetadataPrefix    = "metadataPrefix"
+	KeyEncoding          = "encoding"
+	KeyBufferSize        = "bufferSize"
+	KeyOverflowPolicy    = "overflowPolicy"
+	KeyReconnectAttempts = "reconnectMaxAttempts"
+	KeyReconnectMaxTime  = "reconnectMaxTime"
+	KeyReconnectBackoff  = "reconnectBackoff"
+	KeyAtomic            = "atomic"
+
+	KeyTLSEnabled            = "redis.tls.enabled"
+	KeyTLSCAFile             = "redis.tls.caFile"
+	KeyTLSCertFile           = "redis.tls.certFile"
+	KeyTLSKeyFile            = "redis.tls.keyFile"
+	KeyTLSServerName         = "redis.tls.serverName"
+	KeyTLSInsecureSkipVerify = "redis.tls.insecureSkipVerify"
+
+	KeySentinelAddresses  = "redis.sentinel.addresses"
+	KeySentinelMasterName = "redis.sentinel.masterName"
+
+	KeyClusterAddresses = "redis.cluster.addresses"
+
+	KeyURL = "redis.url"
 
 	defaultHost          = "localhost"
 	defaultPort          = "6379"
 	defaultPollingPeriod = "1s"
+	defaultBackoff       = 100 * time.Millisecond
+
+	startFromEarliest = "earliest"
+	startFromLatest   = "latest"
+
+	// StreamIDEarliest and StreamIDLatest are the stream ids the earliest and latest startFrom values are parsed to
+	StreamIDEarliest = "0-0"
+	StreamIDLatest   = "$"
+
+	// OnEndWait keeps the source idle once the endAt bound is reached, OnEndStop stops its iterator as well
+	OnEndWait = "wait"
+	OnEndStop = "stop"
+
+	// GapPolicyFail, GapPolicyWarn and GapPolicyRecord are the behaviors when messages after the position
+	// were trimmed: returning an error, logging a warning or emitting a gap record before continuing
+	GapPolicyFail   = "fail"
+	GapPolicyWarn   = "warn"
+	GapPolicyRecord = "record"
+
+	// PayloadFormatRaw is the JSON of the stream message fields, PayloadFormatStructured is the fields as structured data
+	PayloadFormatRaw        = "raw"
+	PayloadFormatStructured = "structured"
+
+	// FieldTypeInt, FieldTypeFloat, FieldTypeBool and FieldTypeJSON are the types the fields of the stream
+	// messages can be coerced to in the structured payload
+	FieldTypeInt   = "int"
+	FieldTypeFloat = "float"
+	FieldTypeBool  = "bool"
+	FieldTypeJSON  = "json"
+
+	// EncodingOpenCDC is the encoding of whole records as the JSON of the opencdc.Record,
+	// which is written to the EncodedRecordField field of the stream messages
+	EncodingOpenCDC    = "opencdc"
+	EncodedRecordField = "record"
+
+	// OverflowBlock, OverflowDropOldest, OverflowDropNewest and OverflowFail are the behaviors when a message is
+	// received while the buffer is full: waiting for a record to be read, dropping the oldest buffered record,
+	// dropping the received message or returning an error
+	OverflowBlock      = "block"
+	OverflowDropOldest = "dropOldest"
+	OverflowDropNewest = "dropNewest"
+	OverflowFail       = "fail"
+)
+
+var (
+	gapPolicyAll     = []string{GapPolicyFail, GapPolicyWarn, GapPolicyRecord}
+	payloadFormatAll = []string{PayloadFormatRaw, PayloadFormatStructured}
+	fieldTypeAll     = []string{FieldTypeInt, FieldTypeFloat, FieldTypeBool, FieldTypeJSON}
+	overflowAll      = []string{OverflowBlock, OverflowDropOldest, OverflowDropNewest, OverflowFail}
 )
 
+// streamIDRegex matches the explicit stream ids, with or without sequence number
+var streamIDRegex = regexp.MustCompile(`^\d+(-\d+)?$`)
+
 type Config struct {
 	// Host is the redis host to connect to. default is localhost
 	Host string
@@ -47,10 +143,41 @@ type Config struct {
 	Password string // Database is an optional parameter used for connecting to a specified database number
 	// default is 0
 	Database int
+	// SocketPath is the path of the unix socket connected to instead of Host and Port, set with a unix:// URL
+	SocketPath string
+	// DialTimeout, ReadTimeout and WriteTimeout are the timeouts of the connections, set with the URL query parameters.
+	// The read timeout applies to the blocking reads as well. default is 0, which disables them
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// TLSEnabled is used for source and destination connectors to connect to redis over TLS. default is false
+	TLSEnabled bool
+	// TLSCAFile is the PEM bundle of the certificate authorities the server certificate is verified with,
+	// the system certificate pool is used when empty
+	TLSCAFile string
+	// TLSCertFile and TLSKeyFile are the PEM client certificate and key presented to the server for mutual TLS
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSServerName is the name the server certificate is verified against, the host is used when empty
+	TLSServerName string
+	// TLSInsecureSkipVerify disables the verification of the server certificate, it should only be used for testing
+	TLSInsecureSkipVerify bool
+	// SentinelAddresses are the host:port addresses of the sentinels queried for the address of the master
+	// named SentinelMasterName, which is connected to instead of Host and Port. The master is resolved again
+	// when reconnecting, so the connectors follow the failovers.
+	SentinelAddresses  []string
+	SentinelMasterName string
+	// ClusterAddresses are the host:port addresses of the redis cluster nodes the topology is discovered from.
+	// When set, each command is sent to the master owning the slot of its key instead of Host and Port.
+	ClusterAddresses []string
 	// RedisKey is the redis key that we want to track
 	// This config expects a valid key name for ModeStream and the key should be of type none or stream
 	// Check the key type in redis using `TYPE <key>`.
-	// For ModePubSub, this config expects a valid channel name to subscribe to.
+	// For ModePubSub and ModeShardPubSub, this config expects a valid channel name to subscribe to.
+	// For ModeKeyspace, this config expects the keys, or patterns, whose keyspace notifications are subscribed to.
+	// For ModeHash, this config expects the patterns of the hash keys which are snapshotted and then captured.
+	// For ModeList, this config expects the name of the list the items are moved from.
+	// For ModeZSet, this config expects the name of the sorted set which is tailed by score.
 	// There is no key type for channels in redis and a channel can have same name as an existing key of DS type in redis.
 	RedisKey string
 	// Mode can be thought of as the redis key type, it is used to start the corresponding iterator.
@@ -58,20 +185,108 @@ type Config struct {
 	// by using the `TYPE key` command, furthermore, the channel name can have a key of some other type existing in the redis.
 	// So, it is required to add this detail manually during configuration
 	Mode Mode
-	// PollingPeriod is only used for source connector in stream mode
-	// This period is used by StreamIterator to poll for new data at regular intervals.
+	// PollingPeriod is only used for source connector in stream, list and zset modes
+	// This period is used by StreamIterator and ZSetIterator to poll for new data at regular intervals,
+	// and by ListIterator as the BLMOVE timeout.
 	PollingPeriod time.Duration
+	// BlockTimeout is only used for source connector in stream mode.
+	// When set, the stream is read with XREAD BLOCK on a dedicated connection instead of being polled every
+	// PollingPeriod, so the new messages are read as soon as they are added. Zero disables blocking reads.
+	BlockTimeout time.Duration
+	// StartFrom is only used for source connector in stream mode.
+	// It is the id the streams are read from when there is no stored position, either StreamIDEarliest,
+	// StreamIDLatest or an explicit stream id. default is "", which reads the streams from the beginning
+	StartFrom string
+	// EndAt is only used for source connector in stream mode.
+	// When set, the streams are read with XRANGE up to this id, either StreamIDLatest, resolved to the id of
+	// the last message when the connector is opened, or an explicit stream id, instead of being polled forever.
+	EndAt string
+	// OnEnd is the behavior once all the messages up to EndAt were read, either OnEndWait or OnEndStop.
+	OnEnd string
+	// GapPolicy is only used for source connector in stream mode.
+	// When set, the streams are checked with XINFO STREAM for messages trimmed after the position, when opening
+	// the connector and before each read, and the gaps are handled with one of the GapPolicy values.
+	GapPolicy string
+	// PayloadFormat is only used for source connector in stream mode.
+	// It is the format of the record payload, either PayloadFormatRaw or PayloadFormatStructured. default is PayloadFormatRaw
+	PayloadFormat string
+	// FieldTypes maps the names of the stream message fields to the type they are coerced to in the structured
+	// payload, the other fields are kept as strings. It requires PayloadFormat to be PayloadFormatStructured.
+	FieldTypes map[string]string
+	// KeyField, OperationField and MetadataPrefix are only used for source connector in stream mode.
+	// KeyField is the message field used as the record key instead of the stream key, OperationField the one holding
+	// the operation of the record, either create, update or delete, and the fields starting with MetadataPrefix
+	// are added to the record metadata without the prefix. The mapped fields are removed from the payload.
+	KeyField       string
+	OperationField string
+	MetadataPrefix string
+	// Encoding is used for source and destination connectors in pubsub, shardpubsub and stream modes.
+	// When set to EncodingOpenCDC, the destination writes the whole records instead of their payload and the source
+	// decodes them back, so redis can be used between two pipelines. default is "", which only writes the payload
+	Encoding string
+	// BufferSize is only used for source connector in pubsub, shardpubsub, keyspace and hash modes.
+	// It is the maximum number of received messages buffered until they are read, the buffer is unbounded when zero.
+	BufferSize int
+	// OverflowPolicy is the behavior when a message is received while the buffer is full, one of the Overflow values.
+	// default is OverflowBlock
+	OverflowPolicy string
+	// ReconnectMaxAttempts is only used for source connector.
+	// When set, the connection is re-established after a connection loss, at most this many times in a row,
+	// and each mode resumes from the last message or item read.
+	// Zero disables reconnecting, in which case the connection loss stops the pipeline, unless the master is resolved
+	// with the sentinels, whose failovers are always followed.
+	ReconnectMaxAttempts int
+	// ReconnectMaxTime bounds the total time spent reconnecting after a connection loss, it is unbounded when zero
+	ReconnectMaxTime time.Duration
+	// ReconnectBackoff is the delay before the first reconnection attempt, doubled after each attempt. default is 100ms
+	ReconnectBackoff time.Duration
+	// Atomic is only used for destination connector in pubsub, shardpubsub and stream modes.
+	// When set, each batch is written in a MULTI/EXEC transaction, or a script in a cluster, so that either all
+	// or none of its records are written.
+	Atomic bool
+	// ConsumerGroup is only used for source connector in stream mode.
+	// When set, the stream is read using XREADGROUP as part of this consumer group and the records are
+	// acknowledged using XACK once conduit acks them. The group is created if it doesn't exist.
+	ConsumerGroup string
+	// ConsumerName is the name of the consumer within ConsumerGroup, required when ConsumerGroup is set.
+	// It should be unique per running connector, as redis tracks the pending messages per consumer.
+	ConsumerName string
+	// ClaimMinIdleTime enables claiming the messages pending in ConsumerGroup for at least this duration,
+	// e.g. the messages read by a consumer which crashed before acknowledging them. Zero disables claiming.
+	ClaimMinIdleTime time.Duration
+	// MaxDeliveries is the number of times a pending message can be delivered before it is moved to
+	// DeadLetterKey instead of being claimed again. Zero means the message is claimed indefinitely.
+	MaxDeliveries int
+	// DeadLetterKey is the stream key the messages exceeding MaxDeliveries are added to,
+	// required when MaxDeliveries is set.
+	DeadLetterKey string
+	// NotifyKeyspaceEvents is only used for source connector in keyspace and hash modes.
+	// When set, the notify-keyspace-events redis config is set to this value using CONFIG SET, to enable the
+	// keyspace notifications without configuring the server manually.
+	NotifyKeyspaceEvents string
+	// ProcessingKey is only used for source connector in list mode.
+	// It is the list the items are moved to while being processed, they are removed from it once acked.
+	// It should be unique per running connector. default is "<redis.key>:processing"
+	ProcessingKey string
 }
 
 // Mode is the type used to supply the type of redis.key supplied in config, it is used to start corresponding iterator
 type Mode string
 
 const (
-	ModePubSub Mode = "pubsub"
-	ModeStream Mode = "stream"
+	ModePubSub      Mode = "pubsub"
+	ModeShardPubSub Mode = "shardpubsub"
+	ModeStream      Mode = "stream"
+	ModeKeyspace    Mode = "keyspace"
+	ModeHash        Mode = "hash"
+	ModeList        Mode = "list"
+	ModeZSet        Mode = "zset"
 )
 
-var modeAll = []string{string(ModePubSub), string(ModeStream)}
+var modeAll = []string{
+	string(ModePubSub), string(ModeShardPubSub), string(ModeStream), string(ModeKeyspace), string(ModeHash), string(ModeList),
+	string(ModeZSet),
+}
 
 // Parse parses and validates the supplied config
 func Parse(cfg map[string]string) (Config, error) {
@@ -117,6 +332,18 @@ func Parse(cfg map[string]string) (Config, error) {
 		config.Database = dbInt
 	}
 
+	if err := parseURL(cfg, &config); err != nil {
+		return Config{}, err
+	}
+
+	if err := parseTLS(cfg, &config); err != nil {
+		return Config{}, err
+	}
+
+	if err := parseSentinel(cfg, &config); err != nil {
+		return Config{}, err
+	}
+
 	if modeRaw := cfg[KeyMode]; modeRaw != "" {
 		if !isModeSupported(modeRaw) {
 			return Config{}, fmt.Errorf("%q contains unsupported value %q, expected one of %v", KeyMode, modeRaw, modeAll)
@@ -125,9 +352,567 @@ func Parse(cfg map[string]string) (Config, error) {
 		config.Mode = Mode(modeRaw)
 	}
 
+	if err := parseCluster(cfg, &config); err != nil {
+		return Config{}, err
+	}
+
+	if err := parseStream(cfg, &config); err != nil {
+		return Config{}, err
+	}
+
+	if err := parseEncoding(cfg, &config); err != nil {
+		return Config{}, err
+	}
+
+	if err := parseConsumerGroup(cfg, &config); err != nil {
+		return Config{}, err
+	}
+
+	if err := parseEndAt(cfg, &config); err != nil {
+		return Config{}, err
+	}
+
+	if events := cfg[KeyNotifyEvents]; events != "" {
+		if config.Mode != ModeKeyspace && config.Mode != ModeHash {
+			return Config{}, fmt.Errorf("%q is only supported in %q and %q modes", KeyNotifyEvents, ModeKeyspace, ModeHash)
+		}
+		config.NotifyKeyspaceEvents = events
+	}
+
+	if err := parseList(cfg, &config); err != nil {
+		return Config{}, err
+	}
+
+	if err := parseBuffer(cfg, &config); err != nil {
+		return Config{}, err
+	}
+
+	if err := parseReconnect(cfg, &config); err != nil {
+		return Config{}, err
+	}
+
+	if err := parseAtomic(cfg, &config); err != nil {
+		return Config{}, err
+	}
+
+	if config.ReadTimeout > 0 {
+		// the blocking reads would fail with a timeout before redis replies
+		if config.ReadTimeout <= config.BlockTimeout || (config.Mode == ModeList && config.ReadTimeout <= config.PollingPeriod) {
+			return Config{}, fmt.Errorf("%s of %q must be longer than %q and, in list mode, %q", queryReadTimeout, KeyURL, KeyBlockTimeout, KeyPollingPeriod)
+		}
+	}
+
 	return config, nil
 }
 
+// parseTLS parses and validates the options used to connect to redis over TLS
+func parseTLS(cfg map[string]string, config *Config) error {
+	if enabled := cfg[KeyTLSEnabled]; enabled != "" {
+		enabledBool, err := strconv.ParseBool(enabled)
+		if err != nil {
+			return errors.New("invalid tls enabled passed, should be a valid bool")
+		}
+		// TLS is enabled by a rediss url even with the default false value
+		config.TLSEnabled = config.TLSEnabled || enabledBool
+	}
+	if skipVerify := cfg[KeyTLSInsecureSkipVerify]; skipVerify != "" {
+		skipVerifyBool, err := strconv.ParseBool(skipVerify)
+		if err != nil {
+			return errors.New("invalid tls insecure skip verify passed, should be a valid bool")
+		}
+		// the default false value is accepted without TLS
+		if skipVerifyBool && !config.TLSEnabled {
+			return fmt.Errorf("%q requires %q to be set", KeyTLSInsecureSkipVerify, KeyTLSEnabled)
+		}
+		config.TLSInsecureSkipVerify = skipVerifyBool
+	}
+	if !config.TLSEnabled {
+		for _, key := range []string{KeyTLSCAFile, KeyTLSCertFile, KeyTLSKeyFile, KeyTLSServerName} {
+			if cfg[key] != "" {
+				return fmt.Errorf("%q requires %q to be set", key, KeyTLSEnabled)
+			}
+		}
+		return nil
+	}
+
+	config.TLSCAFile = cfg[KeyTLSCAFile]
+	config.TLSCertFile = cfg[KeyTLSCertFile]
+	config.TLSKeyFile = cfg[KeyTLSKeyFile]
+	if config.TLSCertFile != "" && config.TLSKeyFile == "" {
+		return fmt.Errorf("%q requires %q to be set", KeyTLSCertFile, KeyTLSKeyFile)
+	}
+	if config.TLSKeyFile != "" && config.TLSCertFile == "" {
+		return fmt.Errorf("%q requires %q to be set", KeyTLSKeyFile, KeyTLSCertFile)
+	}
+	config.TLSServerName = cfg[KeyTLSServerName]
+	return nil
+}
+
+// parseSentinel parses and validates the sentinels the master address is resolved with,
+// which replace the host and port
+func parseSentinel(cfg map[string]string, config *Config) error {
+	addresses, masterName := cfg[KeySentinelAddresses], cfg[KeySentinelMasterName]
+	if addresses == "" {
+		if masterName != "" {
+			return fmt.Errorf("%q requires %q to be set", KeySentinelMasterName, KeySentinelAddresses)
+		}
+		return nil
+	}
+	if masterName == "" {
+		return fmt.Errorf("%q requires %q to be set", KeySentinelAddresses, KeySentinelMasterName)
+	}
+	// the default host and port are passed explicitly, they are ignored
+	if !isUnset(cfg, KeyHost, defaultHost) {
+		return fmt.Errorf("%q can't be used with %q", KeyHost, KeySentinelAddresses)
+	}
+	if !isUnset(cfg, KeyPort, defaultPort) {
+		return fmt.Errorf("%q can't be used with %q", KeyPort, KeySentinelAddresses)
+	}
+
+	for _, part := range strings.Split(addresses, ",") {
+		address := strings.TrimSpace(part)
+		if address == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return fmt.Errorf("invalid sentinel address passed(%v), should be formatted as host:port", address)
+		}
+		config.SentinelAddresses = append(config.SentinelAddresses, address)
+	}
+	if len(config.SentinelAddresses) == 0 {
+		return requiredConfigErr(KeySentinelAddresses)
+	}
+	config.SentinelMasterName = masterName
+	return nil
+}
+
+// parseCluster parses and validates the nodes of the redis cluster, which replace the host and port
+func parseCluster(cfg map[string]string, config *Config) error {
+	addresses := cfg[KeyClusterAddresses]
+	if addresses == "" {
+		return nil
+	}
+	// the default host, port and database are passed explicitly, they are ignored
+	for _, option := range [][2]string{{KeyHost, defaultHost}, {KeyPort, defaultPort}, {KeyDatabase, "0"}} {
+		if !isUnset(cfg, option[0], option[1]) {
+			return fmt.Errorf("%q can't be used with %q", option[0], KeyClusterAddresses)
+		}
+	}
+	if cfg[KeySentinelAddresses] != "" {
+		return fmt.Errorf("%q can't be used with %q", KeySentinelAddresses, KeyClusterAddresses)
+	}
+	if config.Mode == ModeShardPubSub {
+		// the sharded channels are subscribed to on the master owning their slot, with a single connection
+		keys := config.Keys()
+		for _, key := range keys {
+			if cluster.Slot(key) != cluster.Slot(keys[0]) {
+				return fmt.Errorf("the channels of %q must be in the same cluster slot in %q mode, use a hash tag",
+					KeyRedisKey, ModeShardPubSub)
+			}
+		}
+	}
+
+	for _, part := range strings.Split(addresses, ",") {
+		address := strings.TrimSpace(part)
+		if address == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return fmt.Errorf("invalid cluster address passed(%v), should be formatted as host:port", address)
+		}
+		config.ClusterAddresses = append(config.ClusterAddresses, address)
+	}
+	if len(config.ClusterAddresses) == 0 {
+		return requiredConfigErr(KeyClusterAddresses)
+	}
+	return nil
+}
+
+// parseStream parses and validates the options used to read the streams without consumer group
+func parseStream(cfg map[string]string, config *Config) error {
+	if blockTimeout := cfg[KeyBlockTimeout]; blockTimeout != "" {
+		blockDuration, err := time.ParseDuration(blockTimeout)
+		if err != nil || blockDuration < time.Millisecond {
+			return fmt.Errorf("invalid block timeout passed(%v), should be at least 1ms", blockTimeout)
+		}
+		if config.Mode != ModeStream {
+			return fmt.Errorf("%q is only supported in %q mode", KeyBlockTimeout, ModeStream)
+		}
+		config.BlockTimeout = blockDuration
+	}
+
+	startFrom := cfg[KeyStartFrom]
+	if config.Mode != ModeStream && isUnset(cfg, KeyStartFrom, startFromEarliest) {
+		startFrom = ""
+	}
+	if startFrom != "" {
+		if config.Mode != ModeStream {
+			return fmt.Errorf("%q is only supported in %q mode", KeyStartFrom, ModeStream)
+		}
+		var err error
+		if config.StartFrom, err = parseStartFrom(startFrom); err != nil {
+			return err
+		}
+	}
+
+	if gapPolicy := cfg[KeyGapPolicy]; gapPolicy != "" {
+		if config.Mode != ModeStream {
+			return fmt.Errorf("%q is only supported in %q mode", KeyGapPolicy, ModeStream)
+		}
+		if !slices.Contains(gapPolicyAll, gapPolicy) {
+			return fmt.Errorf("%q contains unsupported value %q, expected one of %v", KeyGapPolicy, gapPolicy, gapPolicyAll)
+		}
+		// the messages of a consumer group are tracked by redis, not by the position
+		if cfg[KeyConsumerGroup] != "" {
+			return fmt.Errorf("%q can't be used with %q", KeyGapPolicy, KeyConsumerGroup)
+		}
+		config.GapPolicy = gapPolicy
+	}
+
+	for _, key := range []string{KeyKeyField, KeyOperationField, KeyMetadataPrefix} {
+		if cfg[key] != "" && config.Mode != ModeStream {
+			return fmt.Errorf("%q is only supported in %q mode", key, ModeStream)
+		}
+	}
+	config.KeyField = cfg[KeyKeyField]
+	config.OperationField = cfg[KeyOperationField]
+	config.MetadataPrefix = cfg[KeyMetadataPrefix]
+	if config.KeyField != "" && config.KeyField == config.OperationField {
+		return fmt.Errorf("%q must be different from %q", KeyOperationField, KeyKeyField)
+	}
+
+	return parsePayloadFormat(cfg, config)
+}
+
+// parsePayloadFormat parses and validates the payload format of the stream records and the types of their fields,
+// formatted as a comma separated list of <field>:<type>
+func parsePayloadFormat(cfg map[string]string, config *Config) error {
+	format := cfg[KeyPayloadFormat]
+	if config.Mode != ModeStream && isUnset(cfg, KeyPayloadFormat, PayloadFormatRaw) {
+		format = ""
+	}
+	if format != "" {
+		if config.Mode != ModeStream {
+			return fmt.Errorf("%q is only supported in %q mode", KeyPayloadFormat, ModeStream)
+		}
+		if !slices.Contains(payloadFormatAll, format) {
+			return fmt.Errorf("%q contains unsupported value %q, expected one of %v", KeyPayloadFormat, format, payloadFormatAll)
+		}
+		config.PayloadFormat = format
+	}
+
+	fieldTypes := cfg[KeyFieldTypes]
+	if fieldTypes == "" {
+		return nil
+	}
+	if config.PayloadFormat != PayloadFormatStructured {
+		return fmt.Errorf("%q requires %q to be %q", KeyFieldTypes, KeyPayloadFormat, PayloadFormatStructured)
+	}
+	config.FieldTypes = make(map[string]string)
+	for _, part := range strings.Split(fieldTypes, ",") {
+		field, fieldType, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok || field == "" {
+			return fmt.Errorf("invalid field type passed(%v), expected <field>:<type>", part)
+		}
+		if !slices.Contains(fieldTypeAll, fieldType) {
+			return fmt.Errorf("%q contains unsupported type %q for field %q, expected one of %v", KeyFieldTypes, fieldType, field, fieldTypeAll)
+		}
+		config.FieldTypes[field] = fieldType
+	}
+	return nil
+}
+
+// parseEncoding parses and validates the encoding of the records, the whole records are decoded as they were written,
+// so they can't be combined with the options building the records from the stream message fields
+func parseEncoding(cfg map[string]string, config *Config) error {
+	encoding := cfg[KeyEncoding]
+	if encoding == "" {
+		return nil
+	}
+	if encoding != EncodingOpenCDC {
+		return fmt.Errorf("%q contains unsupported value %q, expected one of %v", KeyEncoding, encoding, []string{EncodingOpenCDC})
+	}
+	if config.Mode != ModePubSub && config.Mode != ModeShardPubSub && config.Mode != ModeStream {
+		return fmt.Errorf("%q is only supported in %q, %q and %q modes", KeyEncoding, ModePubSub, ModeShardPubSub, ModeStream)
+	}
+	if !isUnset(cfg, KeyPayloadFormat, PayloadFormatRaw) {
+		return fmt.Errorf("%q can't be used with %q", KeyPayloadFormat, KeyEncoding)
+	}
+	for _, key := range []string{KeyKeyField, KeyOperationField, KeyMetadataPrefix} {
+		if cfg[key] != "" {
+			return fmt.Errorf("%q can't be used with %q", key, KeyEncoding)
+		}
+	}
+	config.Encoding = encoding
+	return nil
+}
+
+// parseList parses and validates the processing list of list mode, list and zset modes read a single key
+func parseList(cfg map[string]string, config *Config) error {
+	if config.Mode == ModeList || config.Mode == ModeZSet {
+		if keys := config.Keys(); len(keys) != 1 || IsPattern(keys[0]) {
+			return fmt.Errorf("%q mode supports a single key, got %q", config.Mode, config.RedisKey)
+		}
+	}
+
+	processingKey := cfg[KeyProcessingKey]
+	if config.Mode != ModeList {
+		if processingKey != "" {
+			return fmt.Errorf("%q is only supported in %q mode", KeyProcessingKey, ModeList)
+		}
+		return nil
+	}
+
+	key := config.Keys()[0]
+	if processingKey == "" {
+		processingKey = key + ":processing"
+		if len(config.ClusterAddresses) > 0 && cluster.HashTag(key) == key {
+			// BLMOVE requires both lists in the same slot, the hash tag of the key keeps them together
+			processingKey = "{" + key + "}:processing"
+		}
+	}
+	if processingKey == key {
+		return fmt.Errorf("%q must be different from %q", KeyProcessingKey, KeyRedisKey)
+	}
+	if len(config.ClusterAddresses) > 0 && cluster.Slot(processingKey) != cluster.Slot(key) {
+		return fmt.Errorf("%q must be in the same cluster slot as %q, use a hash tag", KeyProcessingKey, KeyRedisKey)
+	}
+	config.ProcessingKey = processingKey
+
+	return nil
+}
+
+// parseBuffer parses and validates the size of the buffer holding the messages received by the pubsub based modes
+// and the behavior when it is full
+func parseBuffer(cfg map[string]string, config *Config) error {
+	bufferSize := cfg[KeyBufferSize]
+	if bufferSize == "" {
+		if !isUnset(cfg, KeyOverflowPolicy, OverflowBlock) {
+			return fmt.Errorf("%q requires %q to be set", KeyOverflowPolicy, KeyBufferSize)
+		}
+		return nil
+	}
+
+	switch config.Mode {
+	case ModePubSub, ModeShardPubSub, ModeKeyspace, ModeHash:
+	default:
+		if isUnset(cfg, KeyBufferSize, "0") && isUnset(cfg, KeyOverflowPolicy, OverflowBlock) {
+			return nil
+		}
+		return fmt.Errorf("%q is only supported in %q, %q, %q and %q modes", KeyBufferSize, ModePubSub, ModeShardPubSub, ModeKeyspace, ModeHash)
+	}
+	size, err := strconv.Atoi(bufferSize)
+	if err != nil || size < 0 {
+		return errors.New("invalid buffer size passed, should be a valid positive int")
+	}
+	config.BufferSize = size
+
+	config.OverflowPolicy = OverflowBlock
+	if policy := cfg[KeyOverflowPolicy]; policy != "" {
+		if !slices.Contains(overflowAll, policy) {
+			return fmt.Errorf("%q contains unsupported value %q, expected one of %v", KeyOverflowPolicy, policy, overflowAll)
+		}
+		config.OverflowPolicy = policy
+	}
+	return nil
+}
+
+// parseAtomic parses and validates whether the destination writes each batch at once
+func parseAtomic(cfg map[string]string, config *Config) error {
+	atomic := cfg[KeyAtomic]
+	if atomic == "" {
+		return nil
+	}
+
+	atomicBool, err := strconv.ParseBool(atomic)
+	if err != nil {
+		return errors.New("invalid atomic passed, should be a valid bool")
+	}
+	if !atomicBool {
+		return nil
+	}
+	switch config.Mode {
+	case ModePubSub, ModeShardPubSub, ModeStream:
+	default:
+		return fmt.Errorf("%q is only supported in %q, %q and %q modes", KeyAtomic, ModePubSub, ModeShardPubSub, ModeStream)
+	}
+	config.Atomic = true
+	return nil
+}
+
+// parseReconnect parses and validates the options used to reconnect after a connection loss. With the sentinels,
+// the failovers are followed with the default max attempts even when reconnecting isn't enabled otherwise.
+func parseReconnect(cfg map[string]string, config *Config) error {
+	if isUnset(cfg, KeyReconnectAttempts, "0") && len(config.SentinelAddresses) == 0 {
+		if cfg[KeyReconnectMaxTime] != "" {
+			return fmt.Errorf("%q requires %q to be set", KeyReconnectMaxTime, KeyReconnectAttempts)
+		}
+		if !isUnset(cfg, KeyReconnectBackoff, defaultBackoff.String()) {
+			return fmt.Errorf("%q requires %q to be set", KeyReconnectBackoff, KeyReconnectAttempts)
+		}
+		return nil
+	}
+
+	if maxAttempts := cfg[KeyReconnectAttempts]; maxAttempts != "" {
+		maxAttemptsInt, err := strconv.Atoi(maxAttempts)
+		if err != nil || maxAttemptsInt < 0 {
+			return errors.New("invalid reconnect max attempts passed, should be a valid positive int")
+		}
+		config.ReconnectMaxAttempts = maxAttemptsInt
+	}
+
+	if maxTime := cfg[KeyReconnectMaxTime]; maxTime != "" {
+		maxTimeDuration, err := time.ParseDuration(maxTime)
+		if err != nil || maxTimeDuration < 0 {
+			return fmt.Errorf("invalid reconnect max time passed(%v)", maxTime)
+		}
+		config.ReconnectMaxTime = maxTimeDuration
+	}
+
+	config.ReconnectBackoff = defaultBackoff
+	if backoff := cfg[KeyReconnectBackoff]; backoff != "" {
+		backoffDuration, err := time.ParseDuration(backoff)
+		if err != nil || backoffDuration <= 0 {
+			return fmt.Errorf("invalid reconnect backoff passed(%v)", backoff)
+		}
+		config.ReconnectBackoff = backoffDuration
+	}
+	return nil
+}
+
+// parseStartFrom parses the startFrom value to the stream id to start reading from, the timestamps
+// are converted to the id of their millisecond
+func parseStartFrom(startFrom string) (string, error) {
+	switch {
+	case startFrom == startFromEarliest:
+		return StreamIDEarliest, nil
+	case startFrom == startFromLatest:
+		return StreamIDLatest, nil
+	case streamIDRegex.MatchString(startFrom):
+		return startFrom, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, startFrom)
+	if err != nil || t.UnixMilli() < 0 {
+		return "", fmt.Errorf("invalid start from passed(%v), expected %q, %q, a stream id or a RFC3339 timestamp",
+			startFrom, startFromEarliest, startFromLatest)
+	}
+	return fmt.Sprintf("%d-0", t.UnixMilli()), nil
+}
+
+// parseEndAt parses and validates the end bound of the streams, which can't be combined with the options
+// waiting for new messages. The timestamps are converted to the id of their millisecond, which includes all
+// the messages added in that millisecond when used as the XRANGE end
+func parseEndAt(cfg map[string]string, config *Config) error {
+	endAt := cfg[KeyEndAt]
+	if endAt == "" {
+		if !isUnset(cfg, KeyOnEnd, OnEndWait) {
+			return fmt.Errorf("%q requires %q to be set", KeyOnEnd, KeyEndAt)
+		}
+		return nil
+	}
+
+	if config.Mode != ModeStream {
+		return fmt.Errorf("%q is only supported in %q mode", KeyEndAt, ModeStream)
+	}
+	if config.ConsumerGroup != "" || config.BlockTimeout > 0 {
+		return fmt.Errorf("%q can't be used with %q or %q", KeyEndAt, KeyConsumerGroup, KeyBlockTimeout)
+	}
+
+	switch {
+	case endAt == startFromLatest:
+		config.EndAt = StreamIDLatest
+	case streamIDRegex.MatchString(endAt):
+		config.EndAt = endAt
+	default:
+		t, err := time.Parse(time.RFC3339, endAt)
+		if err != nil || t.UnixMilli() < 0 {
+			return fmt.Errorf("invalid end at passed(%v), expected %q, a stream id or a RFC3339 timestamp", endAt, startFromLatest)
+		}
+		config.EndAt = strconv.FormatInt(t.UnixMilli(), 10)
+	}
+
+	config.OnEnd = OnEndWait
+	if onEnd := cfg[KeyOnEnd]; onEnd != "" {
+		if onEnd != OnEndWait && onEnd != OnEndStop {
+			return fmt.Errorf("%q contains unsupported value %q, expected one of %v", KeyOnEnd, onEnd, []string{OnEndWait, OnEndStop})
+		}
+		config.OnEnd = onEnd
+	}
+	return nil
+}
+
+// parseConsumerGroup parses and validates the consumer group related configs of stream mode
+func parseConsumerGroup(cfg map[string]string, config *Config) error {
+	group := cfg[KeyConsumerGroup]
+	if group == "" {
+		for _, key := range []string{KeyClaimMinIdle, KeyDeadLetterKey} {
+			if cfg[key] != "" {
+				return fmt.Errorf("%q requires %q to be set", key, KeyConsumerGroup)
+			}
+		}
+		if !isUnset(cfg, KeyMaxDeliveries, "0") {
+			return fmt.Errorf("%q requires %q to be set", KeyMaxDeliveries, KeyConsumerGroup)
+		}
+		return nil
+	}
+
+	if config.Mode != ModeStream {
+		return fmt.Errorf("%q is only supported in %q mode", KeyConsumerGroup, ModeStream)
+	}
+	name := cfg[KeyConsumerName]
+	if name == "" {
+		return requiredConfigErr(KeyConsumerName)
+	}
+	config.ConsumerGroup = group
+	config.ConsumerName = name
+
+	if minIdle := cfg[KeyClaimMinIdle]; minIdle != "" {
+		minIdleDuration, err := time.ParseDuration(minIdle)
+		if err != nil || minIdleDuration < 0 {
+			return fmt.Errorf("invalid claim min idle time passed(%v)", minIdle)
+		}
+		config.ClaimMinIdleTime = minIdleDuration
+	}
+
+	if maxDeliveries := cfg[KeyMaxDeliveries]; maxDeliveries != "" {
+		maxDeliveriesInt, err := strconv.Atoi(maxDeliveries)
+		if err != nil || maxDeliveriesInt < 0 {
+			return errors.New("invalid max deliveries passed, should be a valid positive int")
+		}
+		config.MaxDeliveries = maxDeliveriesInt
+	}
+
+	config.DeadLetterKey = cfg[KeyDeadLetterKey]
+	if config.MaxDeliveries > 0 {
+		if config.ClaimMinIdleTime == 0 {
+			return fmt.Errorf("%q requires %q to be set", KeyMaxDeliveries, KeyClaimMinIdle)
+		}
+		if config.DeadLetterKey == "" {
+			return requiredConfigErr(KeyDeadLetterKey)
+		}
+	}
+
+	return nil
+}
+
+// Keys returns the keys in RedisKey, which can hold a comma separated list of keys
+func (c Config) Keys() []string {
+	parts := strings.Split(c.RedisKey, ",")
+	keys := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if key := strings.TrimSpace(part); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// IsPattern returns whether the key is a glob-style pattern matching multiple keys
+func IsPattern(key string) bool {
+	return strings.ContainsAny(key, "*?[")
+}
+
 // isModeSupported is used to validate the supplied mode string
 func isModeSupported(modeRaw string) bool {
 	for _, m := range modeAll {
@@ -138,6 +923,13 @@ func isModeSupported(modeRaw string) bool {
 	return false
 }
 
+// isUnset returns whether the key is empty or holds its default value, which the SDK passes explicitly
+// for the parameters declaring one. The default values are ignored by the modes and options they don't apply to,
+// instead of conflicting with them.
+func isUnset(cfg map[string]string, key, defaultValue string) bool {
+	return cfg[key] == "" || cfg[key] == defaultValue
+}
+
 // requiredConfigErr is a helper function to generate required config error
 func requiredConfigErr(name string) error {
 	return fmt.Errorf("%q config value must be set", name)
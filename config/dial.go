@@ -0,0 +1,124 @@
+// Copyright © 2022 Meroxa, Inc. & Gophers Lab Technologies Pvt. Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/conduitio-labs/conduit-connector-redis/cluster"
+	"github.com/gomodule/redigo/redis"
+)
+
+// Dial creates a new connection to redis, to the master resolved with the sentinels when they are set,
+// or routing the commands to the nodes of the cluster
+func (c Config) Dial(ctx context.Context) (redis.Conn, error) {
+	dialOptions, err := c.DialOptions()
+	if err != nil {
+		return nil, err
+	}
+	if len(c.SentinelAddresses) > 0 {
+		return c.dialMaster(ctx, dialOptions)
+	}
+	if len(c.ClusterAddresses) > 0 {
+		conn, err := cluster.NewConn(ctx, c.ClusterAddresses, c.TLSEnabled, func(ctx context.Context, address string) (redis.Conn, error) {
+			return redis.DialContext(ctx, "tcp", address, dialOptions...)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect redis cluster: %w", err)
+		}
+		return conn, nil
+	}
+
+	// the brackets of the IPv6 hosts are added back
+	network, address := "tcp", net.JoinHostPort(c.Host, c.Port)
+	if c.SocketPath != "" {
+		network, address = "unix", c.SocketPath
+	}
+	redisClient, err := redis.DialContext(ctx, network, address, dialOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect redis client: %w", err)
+	}
+	return redisClient, nil
+}
+
+// DialOptions returns the options used by the source and the destination to connect to redis
+func (c Config) DialOptions() ([]redis.DialOption, error) {
+	dialOptions := make([]redis.DialOption, 0)
+	if c.Password != "" {
+		dialOptions = append(dialOptions, redis.DialPassword(c.Password))
+	}
+	if c.Username != "" {
+		dialOptions = append(dialOptions, redis.DialUsername(c.Username))
+	}
+	dialOptions = append(dialOptions, redis.DialDatabase(c.Database))
+	if c.DialTimeout > 0 {
+		dialOptions = append(dialOptions, redis.DialConnectTimeout(c.DialTimeout))
+	}
+	if c.ReadTimeout > 0 {
+		dialOptions = append(dialOptions, redis.DialReadTimeout(c.ReadTimeout))
+	}
+	if c.WriteTimeout > 0 {
+		dialOptions = append(dialOptions, redis.DialWriteTimeout(c.WriteTimeout))
+	}
+
+	if c.TLSEnabled {
+		tlsConfig, err := c.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		dialOptions = append(dialOptions,
+			redis.DialUseTLS(true),
+			redis.DialTLSConfig(tlsConfig),
+		)
+	}
+	return dialOptions, nil
+}
+
+// tlsConfig loads the CA bundle and the client certificate into the TLS config,
+// redigo verifies the server certificate against the dialed host when the server name isn't set
+func (c Config) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.TLSServerName,
+		InsecureSkipVerify: c.TLSInsecureSkipVerify, //nolint:gosec // opt-in, meant for testing
+	}
+
+	if c.TLSCAFile != "" {
+		caPEM, err := os.ReadFile(c.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the tls CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("no certificate found in the tls CA file")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if c.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the tls client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,222 @@
+// Copyright © 2022 Meroxa, Inc. & Gophers Lab Technologies Pvt. Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_DialOptions_TLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := newCertificate(t, nil, nil, "ca")
+	server, serverKey := newCertificate(t, ca, caKey, "server")
+	client, clientKey := newCertificate(t, ca, caKey, "client")
+	caFile := writePEM(t, dir, "ca.pem", ca.Raw, nil)
+	certFile := writePEM(t, dir, "client.pem", client.Raw, nil)
+	keyFile := writePEM(t, dir, "client-key.pem", nil, clientKey)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	mr, err := miniredis.RunTLS(&tls.Config{
+		MinVersion: tls.VersionTLS12,
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{server.Raw},
+			PrivateKey:  serverKey,
+		}},
+		ClientCAs:  pool,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+	})
+	require.NoError(t, err)
+	defer mr.Close()
+
+	host, port, err := net.SplitHostPort(mr.Addr())
+	require.NoError(t, err)
+	mTLS := Config{
+		Host:        host,
+		Port:        port,
+		TLSEnabled:  true,
+		TLSCAFile:   caFile,
+		TLSCertFile: certFile,
+		TLSKeyFile:  keyFile,
+	}
+
+	tests := []struct {
+		name    string
+		config  func(Config) Config
+		wantErr string
+	}{
+		{
+			name:   "mutual TLS",
+			config: func(c Config) Config { return c },
+		},
+		{
+			name: "without client certificate",
+			config: func(c Config) Config {
+				c.TLSCertFile, c.TLSKeyFile = "", ""
+				return c
+			},
+			wantErr: "certificate required",
+		},
+		{
+			name: "server name mismatch",
+			config: func(c Config) Config {
+				c.TLSServerName = "redis.example.com"
+				return c
+			},
+			wantErr: "wanted to match redis.example.com",
+		},
+		{
+			name: "insecure skip verify",
+			config: func(c Config) Config {
+				c.TLSCAFile, c.TLSServerName, c.TLSInsecureSkipVerify = "", "redis.example.com", true
+				return c
+			},
+		},
+		{
+			name: "missing CA file",
+			config: func(c Config) Config {
+				c.TLSCAFile = filepath.Join(dir, "missing.pem")
+				return c
+			},
+			wantErr: "failed to read the tls CA file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.config(mTLS)
+			err := func() error {
+				dialOptions, err := cfg.DialOptions()
+				if err != nil {
+					return err
+				}
+				conn, err := redis.DialContext(context.Background(), "tcp", mr.Addr(), dialOptions...)
+				if err != nil {
+					return err
+				}
+				defer conn.Close()
+				_, err = conn.Do("PING")
+				return err
+			}()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+// newCertificate creates a certificate for 127.0.0.1, self-signed when the parent is nil
+func newCertificate(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, name string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+// writePEM writes either the certificate or the key to a PEM file in dir
+func writePEM(t *testing.T, dir, name string, cert []byte, key *ecdsa.PrivateKey) string {
+	t.Helper()
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: cert}
+	if key != nil {
+		der, err := x509.MarshalECPrivateKey(key)
+		require.NoError(t, err)
+		block = &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+	}
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(block), 0o600))
+	return path
+}
+
+func TestConfig_Dial_URL(t *testing.T) {
+	mr := miniredis.RunT(t)
+	mr.RequireUserAuth("user", "secret")
+
+	cfg, err := Parse(map[string]string{
+		KeyRedisKey: "my_key",
+		KeyURL:      "redis://user:secret@" + mr.Addr() + "/2?dial_timeout=1s&read_timeout=1s&write_timeout=1s",
+	})
+	require.NoError(t, err)
+	conn, err := cfg.Dial(context.Background())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Do("SET", "key", "value")
+	assert.NoError(t, err)
+	mr.Select(2)
+	value, err := mr.Get("key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+}
+
+func TestConfig_Dial_IPv6(t *testing.T) {
+	mr := miniredis.NewMiniRedis()
+	if err := mr.StartAddr("[::1]:0"); err != nil {
+		t.Skipf("IPv6 isn't available: %v", err)
+	}
+	defer mr.Close()
+
+	// the host is parsed without the brackets, they are added back when dialing
+	cfg, err := Parse(map[string]string{
+		KeyRedisKey: "my_key",
+		KeyURL:      "redis://" + mr.Addr(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "::1", cfg.Host)
+	conn, err := cfg.Dial(context.Background())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	reply, err := redis.String(conn.Do("PING"))
+	assert.NoError(t, err)
+	assert.Equal(t, "PONG", reply)
+}
@@ -0,0 +1,105 @@
+// Copyright © 2022 Meroxa, Inc. & Gophers Lab Technologies Pvt. Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// roleMaster is the role reported by ROLE on the master
+const roleMaster = "master"
+
+// dialMaster asks the sentinels, in order, for the address of the master and connects to it. The role of the
+// instance is verified, as a sentinel can report the previous master while a failover is in progress.
+func (c Config) dialMaster(ctx context.Context, dialOptions []redis.DialOption) (redis.Conn, error) {
+	errs := make([]error, 0, len(c.SentinelAddresses))
+	for _, sentinel := range c.SentinelAddresses {
+		address, err := c.masterAddress(ctx, sentinel)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("sentinel(%s): %w", sentinel, err))
+			continue
+		}
+
+		redisClient, err := redis.DialContext(ctx, "tcp", address, dialOptions...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("master(%s) from sentinel(%s): %w", address, sentinel, err))
+			continue
+		}
+		role, err := redis.Values(redisClient.Do("ROLE"))
+		if err == nil && len(role) > 0 {
+			var name string
+			if name, err = redis.String(role[0], nil); err == nil && name != roleMaster {
+				err = fmt.Errorf("instance has role %q", name)
+			}
+		}
+		if err != nil {
+			redisClient.Close()
+			errs = append(errs, fmt.Errorf("master(%s) from sentinel(%s): %w", address, sentinel, err))
+			continue
+		}
+		return redisClient, nil
+	}
+	return nil, fmt.Errorf("failed to connect to the master %q: %w", c.SentinelMasterName, errors.Join(errs...))
+}
+
+// masterAddress returns the address of the master known by the sentinel, the sentinels are connected to with the
+// TLS options but without the credentials and database of the master
+func (c Config) masterAddress(ctx context.Context, sentinel string) (string, error) {
+	dialOptions := make([]redis.DialOption, 0)
+	if c.TLSEnabled {
+		tlsConfig, err := c.tlsConfig()
+		if err != nil {
+			return "", err
+		}
+		dialOptions = append(dialOptions, redis.DialUseTLS(true), redis.DialTLSConfig(tlsConfig))
+	}
+	conn, err := redis.DialContext(ctx, "tcp", sentinel, dialOptions...)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	hostPort, err := redis.Strings(conn.Do("SENTINEL", "get-master-addr-by-name", c.SentinelMasterName))
+	if errors.Is(err, redis.ErrNil) {
+		return "", errors.New("unknown master")
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(hostPort) != 2 {
+		return "", fmt.Errorf("unexpected master address %v", hostPort)
+	}
+	return net.JoinHostPort(hostPort[0], hostPort[1]), nil
+}
+
+// IsFailover returns whether the error is caused by a failover, either a connection loss or a write rejected by
+// the previous master turned into a replica, which are recovered from by dialing again. With the sentinels,
+// dialing again resolves the new master.
+func IsFailover(err error) bool {
+	var netErr net.Error
+	var redisErr redis.Error
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.As(err, &netErr) ||
+		(errors.As(err, &redisErr) && strings.HasPrefix(string(redisErr), "READONLY"))
+}
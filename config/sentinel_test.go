@@ -0,0 +1,97 @@
+// Copyright © 2022 Meroxa, Inc. & Gophers Lab Technologies Pvt. Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsFailover(t *testing.T) {
+	assert.True(t, IsFailover(io.EOF))
+	assert.True(t, IsFailover(fmt.Errorf("error reading: %w", net.ErrClosed)))
+	assert.True(t, IsFailover(redis.Error("READONLY You can't write against a read only replica.")))
+	assert.False(t, IsFailover(redis.Error("ERR wrong number of arguments")))
+	assert.False(t, IsFailover(errors.New("invalid data")))
+}
+
+func TestConfig_Dial_Sentinel(t *testing.T) {
+	master := newInstance(t, "master")
+	replica := newInstance(t, "slave")
+	require.NoError(t, master.Set("instance", "master"))
+
+	// the first sentinel doesn't know the master, the second one reports it
+	unaware := newSentinel(t, "")
+	aware := newSentinel(t, master.Addr())
+
+	cfg := Config{
+		SentinelAddresses:  []string{unaware.Addr(), aware.Addr()},
+		SentinelMasterName: "mymaster",
+	}
+	conn, err := cfg.Dial(context.Background())
+	require.NoError(t, err)
+	instance, err := redis.String(conn.Do("GET", "instance"))
+	assert.NoError(t, err)
+	assert.Equal(t, "master", instance)
+	conn.Close()
+
+	// a sentinel reporting the previous master during a failover is skipped
+	stale := newSentinel(t, replica.Addr())
+	cfg.SentinelAddresses = []string{stale.Addr(), unaware.Addr()}
+	_, err = cfg.Dial(context.Background())
+	assert.ErrorContains(t, err, `failed to connect to the master "mymaster"`)
+	assert.ErrorContains(t, err, `instance has role "slave"`)
+	assert.ErrorContains(t, err, "unknown master")
+}
+
+// newInstance returns a redis instance replying to ROLE with the role
+func newInstance(t *testing.T, role string) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	require.NoError(t, mr.Server().Register("ROLE", func(c *server.Peer, _ string, _ []string) {
+		c.WriteLen(1)
+		c.WriteBulk(role)
+	}))
+	return mr
+}
+
+// newSentinel returns a sentinel reporting the master address of mymaster, which is unknown when empty
+func newSentinel(t *testing.T, masterAddress string) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	require.NoError(t, mr.Server().Register("SENTINEL", func(c *server.Peer, _ string, args []string) {
+		if len(args) != 2 || args[0] != "get-master-addr-by-name" {
+			c.WriteError("ERR unsupported sentinel command")
+			return
+		}
+		if masterAddress == "" || args[1] != "mymaster" {
+			c.WriteNull()
+			return
+		}
+		host, port, _ := net.SplitHostPort(masterAddress)
+		c.WriteStrings([]string{host, port})
+	}))
+	return mr
+}
@@ -0,0 +1,175 @@
+// Copyright © 2022 Meroxa, Inc. & Gophers Lab Technologies Pvt. Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	schemeRedis  = "redis"
+	schemeRedisS = "rediss"
+	schemeUnix   = "unix"
+
+	// the query parameters of the URL, named the same way as in the other redis clients
+	queryDatabase     = "db"
+	queryDialTimeout  = "dial_timeout"
+	queryReadTimeout  = "read_timeout"
+	queryWriteTimeout = "write_timeout"
+)
+
+var (
+	schemeAll = []string{schemeRedis, schemeRedisS, schemeUnix}
+	queryAll  = []string{queryDatabase, queryDialTimeout, queryReadTimeout, queryWriteTimeout}
+)
+
+// parseURL parses the connection URL, which takes precedence over the individual connection options. The options
+// set both in the URL and individually must have the same value, the ones missing from the URL are kept.
+func parseURL(cfg map[string]string, config *Config) error {
+	rawURL := cfg[KeyURL]
+	if rawURL == "" {
+		return nil
+	}
+	for _, key := range []string{KeySentinelAddresses, KeyClusterAddresses} {
+		if cfg[key] != "" {
+			return fmt.Errorf("%q can't be used with %q", key, KeyURL)
+		}
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		// the error holds the URL, which can contain the password
+		return errors.New("invalid redis url passed, should be formatted as redis://[username:password@]host[:port][/database]")
+	}
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return fmt.Errorf("invalid query of %q: %w", KeyURL, err)
+	}
+	for name := range query {
+		if !slices.Contains(queryAll, name) {
+			return fmt.Errorf("%q contains unsupported query parameter %q, expected one of %v", KeyURL, name, queryAll)
+		}
+	}
+
+	switch u.Scheme {
+	case schemeRedis, schemeRedisS:
+		if u.Hostname() == "" {
+			return fmt.Errorf("%q requires a host", KeyURL)
+		}
+		if err := setFromURL(cfg, KeyHost, defaultHost, u.Hostname(), &config.Host); err != nil {
+			return err
+		}
+		if u.Port() != "" {
+			if err := setFromURL(cfg, KeyPort, defaultPort, u.Port(), &config.Port); err != nil {
+				return err
+			}
+		}
+		if u.Scheme == schemeRedisS {
+			enabled, err := strconv.ParseBool(cfg[KeyTLSEnabled])
+			if err == nil && !enabled && !isUnset(cfg, KeyTLSEnabled, "false") {
+				return fmt.Errorf("%q conflicts with the %q scheme of %q", KeyTLSEnabled, schemeRedisS, KeyURL)
+			}
+			config.TLSEnabled = true
+		}
+	case schemeUnix:
+		for _, option := range [][2]string{{KeyHost, defaultHost}, {KeyPort, defaultPort}} {
+			if !isUnset(cfg, option[0], option[1]) {
+				return fmt.Errorf("%q can't be used with a %q url", option[0], schemeUnix)
+			}
+		}
+		if u.Host != "" || u.Path == "" {
+			return fmt.Errorf("%q requires the absolute path of the socket, e.g. unix:///run/redis.sock", KeyURL)
+		}
+		config.SocketPath = u.Path
+		config.Host, config.Port = "", ""
+	default:
+		return fmt.Errorf("%q contains unsupported scheme %q, expected one of %v", KeyURL, u.Scheme, schemeAll)
+	}
+
+	if u.User != nil {
+		if err := setFromURL(cfg, KeyUsername, "", u.User.Username(), &config.Username); err != nil {
+			return err
+		}
+		if password, ok := u.User.Password(); ok {
+			if err := setFromURL(cfg, KeyPassword, "", password, &config.Password); err != nil {
+				return err
+			}
+		}
+	}
+
+	database := query.Get(queryDatabase)
+	if u.Scheme != schemeUnix {
+		if path := strings.Trim(u.Path, "/"); path != "" {
+			if database != "" && database != path {
+				return fmt.Errorf("%q contains the database both in the path and the %q query parameter", KeyURL, queryDatabase)
+			}
+			database = path
+		}
+	}
+	if database != "" {
+		dbInt, err := strconv.Atoi(database)
+		if err != nil {
+			return errors.New("invalid database passed, should be a valid int")
+		}
+		if !isUnset(cfg, KeyDatabase, "0") && dbInt != config.Database {
+			return fmt.Errorf("%q conflicts with the database of %q", KeyDatabase, KeyURL)
+		}
+		config.Database = dbInt
+	}
+
+	for name, timeout := range map[string]*time.Duration{
+		queryDialTimeout:  &config.DialTimeout,
+		queryReadTimeout:  &config.ReadTimeout,
+		queryWriteTimeout: &config.WriteTimeout,
+	} {
+		if value := query.Get(name); value != "" {
+			if *timeout, err = parseTimeout(value); err != nil {
+				return fmt.Errorf("invalid %s passed(%v), should be a duration or a number of seconds", name, value)
+			}
+		}
+	}
+	return nil
+}
+
+// setFromURL sets the value of the option from the URL, returning an error if it was set to another value
+// individually, other than its default value
+func setFromURL(cfg map[string]string, key, defaultValue, value string, field *string) error {
+	if !isUnset(cfg, key, defaultValue) && cfg[key] != value {
+		return fmt.Errorf("%q conflicts with the value in %q", key, KeyURL)
+	}
+	*field = value
+	return nil
+}
+
+// parseTimeout parses the timeout, which is either a time.Duration string or a number of seconds
+func parseTimeout(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, errors.New("negative timeout")
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < 0 {
+		return 0, errors.New("invalid timeout")
+	}
+	return timeout, nil
+}
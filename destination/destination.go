@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/conduitio-labs/conduit-connector-redis/cluster"
 	"github.com/conduitio-labs/conduit-connector-redis/config"
 	cconfig "github.com/conduitio/conduit-commons/config"
 	"github.com/conduitio/conduit-commons/opencdc"
@@ -71,9 +72,57 @@ func (d *Destination) Parameters() cconfig.Parameters {
 			Default:     "",
 			Description: "Username to the redis source",
 		},
+		config.KeyTLSEnabled: {
+			Default:     "false",
+			Description: "Whether to connect to redis over TLS",
+		},
+		config.KeyTLSCAFile: {
+			Default:     "",
+			Description: "Path to the PEM bundle of the CAs the server certificate is verified with, the system pool is used when empty",
+		},
+		config.KeyTLSCertFile: {
+			Default:     "",
+			Description: "Path to the PEM client certificate presented to the server for mutual TLS",
+		},
+		config.KeyTLSKeyFile: {
+			Default:     "",
+			Description: "Path to the PEM private key of the client certificate",
+		},
+		config.KeyTLSServerName: {
+			Default:     "",
+			Description: "Name the server certificate is verified against, the host is used when empty",
+		},
+		config.KeyTLSInsecureSkipVerify: {
+			Default:     "false",
+			Description: "Whether to skip the verification of the server certificate, only meant for testing",
+		},
+		config.KeySentinelAddresses: {
+			Default:     "",
+			Description: "Comma separated list of the host:port addresses of the sentinels the master is resolved with, instead of the host and port",
+		},
+		config.KeySentinelMasterName: {
+			Default:     "",
+			Description: "Name of the master monitored by the sentinels, required when redis.sentinel.addresses is set",
+		},
+		config.KeyClusterAddresses: {
+			Default:     "",
+			Description: "Comma separated list of the host:port addresses of the redis cluster nodes the topology is discovered from, instead of the host and port",
+		},
+		config.KeyURL: {
+			Default:     "",
+			Description: "Connection URL taking precedence over the host, port, database, username and password, either 'redis://', 'rediss://' for TLS or 'unix://' for a socket, with the 'db', 'dial_timeout', 'read_timeout' and 'write_timeout' query parameters",
+		},
 		config.KeyMode: {
 			Default:     "pubsub",
-			Description: "Sets the connector's operation mode. Available modes: ['pubsub', 'stream']",
+			Description: "Sets the connector's operation mode. Available modes: ['pubsub', 'shardpubsub', 'stream']",
+		},
+		config.KeyEncoding: {
+			Default:     "",
+			Description: "Set to 'opencdc' to write the whole records as JSON instead of their payload, only the payload is written when empty",
+		},
+		config.KeyAtomic: {
+			Default:     "false",
+			Description: "Whether to write each batch in a MULTI/EXEC transaction, or a script in a cluster, so that either all or none of its records are written",
 		},
 	}
 }
@@ -91,20 +140,9 @@ func (d *Destination) Configure(ctx context.Context, cfg cconfig.Config) error {
 
 // Open creates a connection to redis and validates the type to key using Type <key> command
 func (d *Destination) Open(ctx context.Context) error {
-	address := d.config.Host + ":" + d.config.Port
-	dialOptions := make([]redis.DialOption, 0)
-
-	if d.config.Password != "" {
-		dialOptions = append(dialOptions, redis.DialPassword(d.config.Password))
-	}
-	if d.config.Username != "" {
-		dialOptions = append(dialOptions, redis.DialUsername(d.config.Username))
-	}
-	dialOptions = append(dialOptions, redis.DialDatabase(d.config.Database))
-
-	redisClient, err := redis.DialContext(ctx, "tcp", address, dialOptions...)
+	redisClient, err := d.config.Dial(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to connect redis client: %w", err)
+		return err
 	}
 
 	d.client = redisClient
@@ -113,8 +151,12 @@ func (d *Destination) Open(ctx context.Context) error {
 }
 
 func (d *Destination) validateKey(client redis.Conn) error {
+	if len(d.config.Keys()) > 1 {
+		return fmt.Errorf("destination supports a single key, got %q", d.config.RedisKey)
+	}
+
 	switch d.config.Mode {
-	case config.ModePubSub:
+	case config.ModePubSub, config.ModeShardPubSub:
 	// no need to verify the type or if the channel exists
 	// as we can create channel with a key even if that key already exists and have some other data type
 
@@ -132,48 +174,244 @@ func (d *Destination) validateKey(client redis.Conn) error {
 	return nil
 }
 
-// Write receives the record to be written and based on the mode either publishes to PUB/SUB channel
-// or add as key-value pair to stream using XADD, the id of the newly added key is generated automatically
+// Write receives the records to be written and based on the mode either publishes them to (sharded) PUB/SUB channel
+// or adds them as key-value pairs to the stream using XADD, the ids of the new messages are generated automatically.
+// The batch is pipelined, or written at once in a transaction when atomic is set.
 func (d *Destination) Write(ctx context.Context, rec []opencdc.Record) (int, error) {
-	key := d.config.RedisKey
+	cmds, convErr := d.commands(rec)
+	if convErr != nil && d.config.Atomic {
+		// none of the records is written
+		return 0, convErr
+	}
 
-	switch d.config.Mode {
-	case config.ModePubSub:
-		for i, r := range rec {
-			_, err := d.doWithCtx(ctx, "PUBLISH", key, string(r.Payload.After.Bytes()))
-			if err != nil {
-				return i, fmt.Errorf("error publishing message to channel(%s): %w", key, err)
-			}
+	n, err := d.write(ctx, cmds)
+	if err != nil {
+		switch d.config.Mode {
+		case config.ModePubSub, config.ModeShardPubSub:
+			return n, fmt.Errorf("error publishing message to channel(%s): %w", d.config.RedisKey, err)
+		default:
+			return n, fmt.Errorf("error streaming message to key(%s):%w", d.config.RedisKey, err)
 		}
+	}
+	// the records before the one which couldn't be converted were written
+	return n, convErr
+}
 
-		return len(rec), nil
+// command is a redis command writing a record
+type command struct {
+	name string
+	args []interface{}
+}
 
-	case config.ModeStream:
-		for i, r := range rec {
-			keyValArgs, err := payloadToStreamArgs(r.Payload.After)
-			if err != nil {
-				return i, fmt.Errorf("invalid payload: %w", err)
+// commands returns the commands writing the records, up to the first record which can't be converted,
+// in which case the conversion error is returned as well
+func (d *Destination) commands(rec []opencdc.Record) ([]command, error) {
+	key := d.config.RedisKey
+	cmds := make([]command, 0, len(rec))
+	for _, r := range rec {
+		switch d.config.Mode {
+		case config.ModePubSub, config.ModeShardPubSub:
+			name := "PUBLISH"
+			if d.config.Mode == config.ModeShardPubSub {
+				name = "SPUBLISH"
+			}
+			message := r.Payload.After.Bytes()
+			if d.config.Encoding == config.EncodingOpenCDC {
+				var err error
+				if message, err = encodeRecord(r); err != nil {
+					return cmds, err
+				}
 			}
+			cmds = append(cmds, command{name: name, args: []interface{}{key, string(message)}})
 
+		case config.ModeStream:
+			keyValArgs, err := d.streamArgs(r)
+			if err != nil {
+				return cmds, err
+			}
 			args := []interface{}{
 				key, "*",
 			}
+			cmds = append(cmds, command{name: "XADD", args: append(args, keyValArgs...)})
 
-			args = append(args, keyValArgs...)
-
-			_, err = d.client.Do("XADD", args...)
-			if err != nil {
-				return i, fmt.Errorf("error streaming message to key(%s):%w", key, err)
-			}
+		default:
+			return cmds, fmt.Errorf("invalid mode(%s) encountered", string(d.config.Mode))
 		}
+	}
+	return cmds, nil
+}
 
-		return len(rec), nil
+// write writes the commands on the connection, and returns the number of commands before the first failed one.
+// When the connection to the master resolved with the sentinels is lost or the master became a replica,
+// the commands which weren't written are retried once on a new connection.
+func (d *Destination) write(ctx context.Context, cmds []command) (int, error) {
+	n, err := d.send(ctx, d.client, cmds)
+	if err == nil || len(d.config.SentinelAddresses) == 0 || !config.IsFailover(err) {
+		return n, err
+	}
 
+	sdk.Logger(ctx).Warn().Err(err).Msg("lost the redis master, resolving it again with the sentinels")
+	conn, dialErr := d.reconnect(ctx)
+	if dialErr != nil {
+		return n, fmt.Errorf("%w, failed to reconnect: %w", err, dialErr)
+	}
+	retried, err := d.send(ctx, conn, cmds[n:])
+	return n + retried, err
+}
+
+// send pipelines the commands, or runs them all at once when atomic is set, in a transaction or, as a transaction
+// can't be routed to the node owning the key in a cluster, in a script
+func (d *Destination) send(ctx context.Context, conn redis.Conn, cmds []command) (int, error) {
+	switch {
+	case !d.config.Atomic:
+		return pipeline(ctx, conn, cmds)
+	case len(d.config.ClusterAddresses) > 0:
+		return script(ctx, conn, d.config.RedisKey, cmds)
 	default:
-		return 0, fmt.Errorf("invalid mode(%s) encountered", string(d.config.Mode))
+		return transaction(ctx, conn, cmds)
 	}
 }
 
+// pipeline sends the commands on the connection and receives all their replies, as the connection is reused,
+// it returns the number of commands before the first failed one and its error. In a cluster, the slot of the key
+// may be moved or migrated to another node, which redirects the failed command and all the following ones, as they
+// write to the same key. The failed command is then run with Do, which follows the redirection and discovers the
+// topology again after a MOVED one, and the following ones are pipelined again.
+func pipeline(ctx context.Context, conn redis.Conn, cmds []command) (int, error) {
+	n, err := pipelineOnce(ctx, conn, cmds)
+	for err != nil && cluster.IsRedirect(err) {
+		if _, err = conn.Do(cmds[n].name, cmds[n].args...); err != nil {
+			return n, err
+		}
+		n++
+		var sent int
+		sent, err = pipelineOnce(ctx, conn, cmds[n:])
+		n += sent
+	}
+	return n, err
+}
+
+// pipelineOnce pipelines the commands and returns the number of commands before the first failed one and its error
+func pipelineOnce(ctx context.Context, conn redis.Conn, cmds []command) (int, error) {
+	if len(cmds) == 0 {
+		return 0, nil
+	}
+	for _, cmd := range cmds {
+		if err := conn.Send(cmd.name, cmd.args...); err != nil {
+			return 0, err
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return 0, err
+	}
+
+	n := len(cmds)
+	var firstErr error
+	for i := range cmds {
+		_, err := receiveWithCtx(ctx, conn)
+		if err == nil {
+			continue
+		}
+		if firstErr == nil {
+			n, firstErr = i, err
+		}
+		if conn.Err() != nil {
+			// the connection is broken, the remaining replies are lost
+			break
+		}
+	}
+	return n, firstErr
+}
+
+// transaction runs the commands in a MULTI/EXEC transaction and returns either all or none of them as written.
+// The transaction is discarded when a command is rejected while queued, however redis doesn't roll back the
+// commands which succeeded when another one fails while running, in which case none of them is reported as written.
+func transaction(ctx context.Context, conn redis.Conn, cmds []command) (int, error) {
+	if len(cmds) == 0 {
+		return 0, nil
+	}
+	if err := conn.Send("MULTI"); err != nil {
+		return 0, err
+	}
+	for _, cmd := range cmds {
+		if err := conn.Send(cmd.name, cmd.args...); err != nil {
+			return 0, err
+		}
+	}
+	if err := conn.Send("EXEC"); err != nil {
+		return 0, err
+	}
+	if err := conn.Flush(); err != nil {
+		return 0, err
+	}
+
+	// the replies of MULTI and of the queued commands, the first error explains why the transaction is discarded
+	var queueErr error
+	for range len(cmds) + 1 {
+		_, err := receiveWithCtx(ctx, conn)
+		if err != nil && queueErr == nil {
+			queueErr = err
+		}
+		if conn.Err() != nil {
+			return 0, queueErr
+		}
+	}
+	replies, err := redis.Values(receiveWithCtx(ctx, conn))
+	if err != nil {
+		if queueErr != nil {
+			return 0, queueErr
+		}
+		return 0, err
+	}
+	for _, reply := range replies {
+		if err, ok := reply.(redis.Error); ok {
+			return 0, err
+		}
+	}
+	return len(cmds), nil
+}
+
+// batchScript runs the commands passed as arguments, each one preceded by its number of arguments.
+// The key is declared so the script is run by the cluster node owning its slot.
+var batchScript = redis.NewScript(1, `
+local i = 1
+while i <= #ARGV do
+	local n = tonumber(ARGV[i])
+	redis.call(unpack(ARGV, i + 1, i + n))
+	i = i + n + 1
+end
+return 1
+`)
+
+// script runs the commands writing to the key in a script, which isn't interrupted by other clients, and returns
+// either all or none of them as written. Like a transaction, the script stops at the first failed command but
+// doesn't roll back the previous ones, in which case none of them is reported as written.
+func script(ctx context.Context, conn redis.Conn, key string, cmds []command) (int, error) {
+	if len(cmds) == 0 {
+		return 0, nil
+	}
+	keysAndArgs := []interface{}{key}
+	for _, cmd := range cmds {
+		keysAndArgs = append(keysAndArgs, len(cmd.args)+1, cmd.name)
+		keysAndArgs = append(keysAndArgs, cmd.args...)
+	}
+	if _, err := batchScript.DoContext(ctx, conn, keysAndArgs...); err != nil {
+		return 0, err
+	}
+	return len(cmds), nil
+}
+
+// reconnect replaces the connection with a new one, to the master resolved again with the sentinels
+func (d *Destination) reconnect(ctx context.Context) (redis.Conn, error) {
+	redisClient, err := d.config.Dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_ = d.client.Close()
+	d.client = redisClient
+	return redisClient, nil
+}
+
 // Teardown is called by conduit server to stop the destination connector
 // the graceful shutdown is performed in this function
 func (d *Destination) Teardown(_ context.Context) error {
@@ -183,12 +421,39 @@ func (d *Destination) Teardown(_ context.Context) error {
 	return nil
 }
 
-func (d *Destination) doWithCtx(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
-	cwt, ok := d.client.(redis.ConnWithContext)
+func receiveWithCtx(ctx context.Context, conn redis.Conn) (interface{}, error) {
+	cwt, ok := conn.(redis.ConnWithContext)
 	if !ok {
-		return d.client.Do(cmd, args)
+		return conn.Receive()
+	}
+	return cwt.ReceiveContext(ctx)
+}
+
+// streamArgs returns the field-value pairs of the stream message the record is written as, which is either
+// the fields of the payload or the encoded record
+func (d *Destination) streamArgs(r opencdc.Record) ([]interface{}, error) {
+	if d.config.Encoding == config.EncodingOpenCDC {
+		b, err := encodeRecord(r)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{config.EncodedRecordField, string(b)}, nil
+	}
+
+	keyValArgs, err := payloadToStreamArgs(r.Payload.After)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+	return keyValArgs, nil
+}
+
+// encodeRecord encodes the whole record as JSON, it is decoded by the source using the same encoding
+func encodeRecord(r opencdc.Record) ([]byte, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding the record: %w", err)
 	}
-	return cwt.DoContext(ctx, cmd, args...)
+	return b, nil
 }
 
 // payloadToStreamArgs converts the payload from the record to args to be sent in redis command
@@ -17,9 +17,14 @@ package destination
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strconv"
+	"sync/atomic"
 	"testing"
 
 	miniredis "github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	"github.com/conduitio-labs/conduit-connector-redis/cluster"
 	"github.com/conduitio-labs/conduit-connector-redis/config"
 	"github.com/conduitio/conduit-commons/opencdc"
 	"github.com/gomodule/redigo/redis"
@@ -122,10 +127,51 @@ func TestOpen(t *testing.T) {
 	assert.NoError(t, d.Open(context.Background()))
 }
 
+func TestWriteSentinelFailover(t *testing.T) {
+	oldMaster, newMaster := miniredis.RunT(t), miniredis.RunT(t)
+	for _, mr := range []*miniredis.Miniredis{oldMaster, newMaster} {
+		assert.NoError(t, mr.Server().Register("ROLE", func(c *server.Peer, _ string, _ []string) {
+			c.WriteLen(1)
+			c.WriteBulk("master")
+		}))
+	}
+	var master atomic.Pointer[miniredis.Miniredis]
+	master.Store(oldMaster)
+	sentinel := miniredis.RunT(t)
+	assert.NoError(t, sentinel.Server().Register("SENTINEL", func(c *server.Peer, _ string, _ []string) {
+		c.WriteStrings([]string{master.Load().Host(), master.Load().Port()})
+	}))
+
+	d := new(Destination)
+	d.config.RedisKey = "mystream"
+	d.config.Mode = config.ModeStream
+	d.config.SentinelAddresses = []string{sentinel.Addr()}
+	d.config.SentinelMasterName = "mymaster"
+	assert.NoError(t, d.Open(context.Background()))
+	defer d.Teardown(context.Background())
+
+	rec := opencdc.Record{Payload: opencdc.Change{After: opencdc.StructuredData{"key": "value"}}}
+	n, err := d.Write(context.Background(), []opencdc.Record{rec})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	// the sentinels promote the new master and the old one goes down
+	master.Store(newMaster)
+	oldMaster.Close()
+
+	n, err = d.Write(context.Background(), []opencdc.Record{rec})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	entries, err := newMaster.Stream("mystream")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
 func TestValidateKey(t *testing.T) {
 	tests := []struct {
 		name string
 		mode config.Mode
+		key  string
 		fn   func(conn *redigomock.Conn)
 		err  error
 	}{
@@ -155,6 +201,12 @@ func TestValidateKey(t *testing.T) {
 				conn.Command("TYPE", "dummy_key").Expect("string")
 			},
 			err: fmt.Errorf("invalid key type: string, expected none or stream"),
+		}, {
+			name: "multiple keys",
+			mode: config.ModeStream,
+			key:  "dummy_key,other_key",
+			fn:   func(*redigomock.Conn) {},
+			err:  fmt.Errorf(`destination supports a single key, got "dummy_key,other_key"`),
 		}, {
 			name: "invalid mode",
 			mode: config.Mode("dummy_mode"),
@@ -169,6 +221,9 @@ func TestValidateKey(t *testing.T) {
 			d := new(Destination)
 			d.config.Mode = tt.mode
 			d.config.RedisKey = "dummy_key"
+			if tt.key != "" {
+				d.config.RedisKey = tt.key
+			}
 			err := d.validateKey(c)
 			if tt.err != nil {
 				assert.EqualError(t, err, tt.err.Error())
@@ -222,6 +277,22 @@ func TestWrite(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "shardpubsub success",
+			data: opencdc.Record{
+				Payload: opencdc.Change{After: opencdc.RawData(validJSON)},
+			},
+			fn: func(conn *redigomock.Conn) {
+				conn.Command("SPUBLISH", key, string(validJSON)).Expect(int64(1))
+			},
+			err: nil,
+			destination: Destination{
+				config: config.Config{
+					Mode:     config.ModeShardPubSub,
+					RedisKey: key,
+				},
+			},
+		},
 		{
 			name: "stream success",
 			data: opencdc.Record{
@@ -253,6 +324,28 @@ func TestWrite(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "stream opencdc encoding",
+			data: opencdc.Record{
+				Operation: opencdc.OperationUpdate,
+				Metadata:  opencdc.Metadata{"source": "billing"},
+				Key:       opencdc.RawData("user:1"),
+				Payload:   opencdc.Change{After: opencdc.StructuredData{"name": "jane"}},
+			},
+			err: nil,
+			fn: func(conn *redigomock.Conn) {
+				encoded := `{"position":null,"operation":"update","metadata":{"source":"billing"},"key":"dXNlcjox",` +
+					`"payload":{"before":null,"after":{"name":"jane"}}}`
+				conn.Command("XADD", key, "*", "record", encoded).Expect("dummy_id")
+			},
+			destination: Destination{
+				config: config.Config{
+					Mode:     config.ModeStream,
+					RedisKey: key,
+					Encoding: config.EncodingOpenCDC,
+				},
+			},
+		},
 		{
 			name: "invalid mode",
 			data: opencdc.Record{
@@ -286,6 +379,185 @@ func TestWrite(t *testing.T) {
 	}
 }
 
+func TestWriteBatch(t *testing.T) {
+	key := "dummy_key"
+	records := make([]opencdc.Record, 5)
+	for i := range records {
+		records[i] = opencdc.Record{Payload: opencdc.Change{After: opencdc.RawData(fmt.Sprintf(`{"n":"%d"}`, i))}}
+	}
+
+	t.Run("failed command", func(t *testing.T) {
+		conn := redigomock.NewConn()
+		defer conn.Close()
+		for i := range records {
+			cmd := conn.Command("XADD", key, "*", "n", strconv.Itoa(i))
+			if i == 2 {
+				cmd.ExpectError(fmt.Errorf("dummy_error"))
+			} else {
+				cmd.Expect("dummy_id")
+			}
+		}
+		d := Destination{client: conn, config: config.Config{Mode: config.ModeStream, RedisKey: key}}
+
+		n, err := d.Write(context.Background(), records)
+		assert.EqualError(t, err, "error streaming message to key(dummy_key):dummy_error")
+		assert.Equal(t, 2, n)
+		// the whole batch is pipelined
+		for i := range records {
+			assert.Equal(t, 1, conn.Stats(conn.Command("XADD", key, "*", "n", strconv.Itoa(i))))
+		}
+	})
+
+	t.Run("invalid record", func(t *testing.T) {
+		conn := redigomock.NewConn()
+		defer conn.Close()
+		for i := range 3 {
+			conn.Command("XADD", key, "*", "n", strconv.Itoa(i)).Expect("dummy_id")
+		}
+		d := Destination{client: conn, config: config.Config{Mode: config.ModeStream, RedisKey: key}}
+
+		batch := slices.Clone(records)
+		batch[3].Payload.After = opencdc.RawData("1,2,3,4")
+		n, err := d.Write(context.Background(), batch)
+		assert.ErrorContains(t, err, "invalid json received in payload")
+		assert.Equal(t, 3, n)
+		assert.NoError(t, conn.ExpectationsWereMet())
+	})
+
+	t.Run("moved slot", func(t *testing.T) {
+		from, to := miniredis.RunT(t), miniredis.RunT(t)
+		// the slot of the key is moved to the second node once two records were written, the first node then
+		// redirects them and, as if it left the cluster, fails the topology discovery done from the second one
+		var written atomic.Int32
+		from.Server().SetPreHook(func(c *server.Peer, cmd string, args ...string) bool {
+			moved := written.Load() >= 2
+			switch {
+			case cmd == "CLUSTER" && moved:
+				c.WriteError("ERR the node left the cluster")
+				return true
+			case cmd == "XADD" && moved:
+				c.WriteError(fmt.Sprintf("MOVED %d %s", cluster.Slot(key), to.Addr()))
+				return true
+			case cmd == "XADD":
+				written.Add(1)
+			}
+			return false
+		})
+		cfg := config.Config{Mode: config.ModeStream, RedisKey: key, ClusterAddresses: []string{from.Addr(), to.Addr()}}
+		conn, err := cfg.Dial(context.Background())
+		assert.NoError(t, err)
+		d := Destination{client: conn, config: cfg}
+		defer d.Teardown(context.Background())
+
+		n, err := d.Write(context.Background(), records)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, n)
+		entries, err := from.Stream(key)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 2)
+		entries, err = to.Stream(key)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 3)
+		for i, entry := range entries {
+			assert.Equal(t, []string{"n", strconv.Itoa(i + 2)}, entry.Values)
+		}
+	})
+}
+
+func TestWriteAtomic(t *testing.T) {
+	key := "dummy_key"
+	records := make([]opencdc.Record, 5)
+	for i := range records {
+		records[i] = opencdc.Record{Payload: opencdc.Change{After: opencdc.RawData(fmt.Sprintf(`{"n":"%d"}`, i))}}
+	}
+
+	t.Run("transaction", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		conn, err := redis.Dial("tcp", mr.Addr())
+		assert.NoError(t, err)
+		d := Destination{client: conn, config: config.Config{Mode: config.ModeStream, RedisKey: key, Atomic: true}}
+		defer d.Teardown(context.Background())
+
+		n, err := d.Write(context.Background(), records)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, n)
+		entries, err := mr.Stream(key)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 5)
+		assert.Equal(t, []string{"n", "4"}, entries[4].Values)
+	})
+
+	t.Run("discarded transaction", func(t *testing.T) {
+		conn := redigomock.NewConn()
+		defer conn.Close()
+		conn.Command("MULTI").Expect("OK")
+		for i := range records {
+			cmd := conn.Command("XADD", key, "*", "n", strconv.Itoa(i))
+			if i == 2 {
+				cmd.ExpectError(redis.Error("READONLY You can't write against a read only replica."))
+			} else {
+				cmd.Expect("QUEUED")
+			}
+		}
+		conn.Command("EXEC").ExpectError(redis.Error("EXECABORT Transaction discarded because of previous errors."))
+		d := Destination{client: conn, config: config.Config{Mode: config.ModeStream, RedisKey: key, Atomic: true}}
+
+		n, err := d.Write(context.Background(), records)
+		assert.EqualError(t, err, "error streaming message to key(dummy_key):READONLY You can't write against a read only replica.")
+		assert.Equal(t, 0, n)
+	})
+
+	t.Run("invalid record", func(t *testing.T) {
+		conn := redigomock.NewConn()
+		defer conn.Close()
+		var flushed bool
+		conn.FlushMock = func() error {
+			flushed = true
+			return nil
+		}
+		d := Destination{client: conn, config: config.Config{Mode: config.ModeStream, RedisKey: key, Atomic: true}}
+
+		batch := slices.Clone(records)
+		batch[3].Payload.After = opencdc.RawData("1,2,3,4")
+		n, err := d.Write(context.Background(), batch)
+		assert.ErrorContains(t, err, "invalid json received in payload")
+		assert.Equal(t, 0, n)
+		// none of the records is sent
+		assert.False(t, flushed)
+	})
+
+	t.Run("cluster script", func(t *testing.T) {
+		// miniredis replies to CLUSTER SHARDS as a single node owning all the slots
+		mr := miniredis.RunT(t)
+		cfg := config.Config{
+			Mode:             config.ModeStream,
+			RedisKey:         key,
+			Atomic:           true,
+			ClusterAddresses: []string{mr.Addr()},
+		}
+		conn, err := cfg.Dial(context.Background())
+		assert.NoError(t, err)
+		_, ok := conn.(*cluster.Conn)
+		assert.True(t, ok)
+		d := Destination{client: conn, config: cfg}
+		defer d.Teardown(context.Background())
+
+		n, err := d.Write(context.Background(), records)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, n)
+		entries, err := mr.Stream(key)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 5)
+		assert.Equal(t, []string{"n", "4"}, entries[4].Values)
+
+		assert.NoError(t, mr.Set("other_key", "value"))
+		d.config.RedisKey = "other_key"
+		n, err = d.Write(context.Background(), records)
+		assert.ErrorContains(t, err, "WRONGTYPE")
+		assert.Equal(t, 0, n)
+	})
+}
+
 func TestTeardown(t *testing.T) {
 	tests := []struct {
 		name   string
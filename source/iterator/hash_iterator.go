@@ -0,0 +1,323 @@
+// Copyright © 2022 Meroxa, Inc. & Gophers Lab Technologies Pvt. Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/conduitio-labs/conduit-connector-redis/cluster"
+	"github.com/conduitio-labs/conduit-connector-redis/config"
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/gomodule/redigo/redis"
+)
+
+// scanCount is the COUNT hint passed to SCAN while taking the snapshot
+const scanCount = 100
+
+// HashIterator takes a snapshot of the hash keys matching the configured patterns using SCAN
+// and then captures their changes using keyspace notifications
+type HashIterator struct {
+	// client is used to take the snapshot, the keyspace iterator uses its own connections
+	client redis.Conn
+	// reconnector replaces client after a connection loss, the batch is scanned again from its cursor
+	reconnector reconnector
+	patterns    []string
+	// nodes are the addresses of the masters scanned in turn for each pattern in a cluster, a single empty address
+	// otherwise, node is the index of the one being scanned
+	nodes []string
+	node  int
+	// match is the index of the pattern being scanned and cursor the SCAN cursor of the next batch
+	match  int
+	cursor string
+	// skipUntil is the last key of the batch read before restarting, the keys up to it are already snapshotted
+	skipUntil string
+	// snapshotDone is true once all the patterns were scanned
+	snapshotDone bool
+	buffer       []opencdc.Record
+	cdc          *PubSubIterator
+}
+
+// hashPosition is the position of the snapshot records, once the snapshot is done
+// the positions of the keyspace notifications are used
+type hashPosition struct {
+	// Match is the pattern being scanned
+	Match string `json:"match"`
+	// Node is the address of the master being scanned in a cluster
+	Node string `json:"node,omitempty"`
+	// Cursor is the SCAN cursor of the batch the key was returned in
+	Cursor string `json:"cursor"`
+	// Key is the snapshotted key
+	Key string `json:"key"`
+}
+
+// NewHashIterator subscribes to the keyspace notifications of the hash keys matching the configured patterns,
+// to not miss the changes made while the snapshot is being taken, and resumes the snapshot from the position.
+// dial is used to reconnect after a connection loss.
+func NewHashIterator(
+	ctx context.Context,
+	subClient, valueClient, client redis.Conn,
+	dial Dialer,
+	cfg config.Config,
+	position opencdc.Position,
+) (*HashIterator, error) {
+	i := &HashIterator{
+		client:      client,
+		reconnector: newReconnector(dial, cfg),
+		patterns:    cfg.Keys(),
+		cursor:      "0",
+	}
+	var err error
+	if i.nodes, err = masterAddresses(client); err != nil {
+		return nil, err
+	}
+	if err := i.parsePosition(position); err != nil {
+		return nil, err
+	}
+
+	i.cdc, err = newKeyspaceIterator(ctx, subClient, valueClient, dial, cfg, true)
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// HasNext returns whether there are any more records to be returned
+func (i *HashIterator) HasNext() bool {
+	return !i.snapshotDone || len(i.buffer) > 0 || i.cdc.HasNext()
+}
+
+// Next returns the snapshot records, scanning the next batch of keys when needed, and then the changes
+func (i *HashIterator) Next(ctx context.Context) (opencdc.Record, error) {
+	for len(i.buffer) == 0 && !i.snapshotDone {
+		err := i.scan(ctx)
+		if err != nil && i.reconnector.canReconnect(err) {
+			err = i.reconnect(ctx, err)
+		}
+		if err != nil {
+			return opencdc.Record{}, err
+		}
+	}
+	if len(i.buffer) > 0 {
+		rec := i.buffer[0]
+		i.buffer = i.buffer[1:]
+		return rec, nil
+	}
+	return i.cdc.Next(ctx)
+}
+
+// Ack is a no-op, as the snapshot can be resumed from any position and the changes can't be delivered again
+func (i *HashIterator) Ack(context.Context, opencdc.Position) error {
+	return nil
+}
+
+// Stop stops the keyspace iterator and closes the snapshot connection
+func (i *HashIterator) Stop() error {
+	return errors.Join(i.cdc.Stop(), i.client.Close())
+}
+
+// reconnect replaces the snapshot connection after a connection loss, the next scan reads the batch again
+func (i *HashIterator) reconnect(ctx context.Context, cause error) error {
+	client, err := i.reconnector.reconnect(ctx, cause, ctx.Done())
+	if err != nil {
+		return err
+	}
+	// the connection is broken already, the error closing it doesn't matter
+	_ = i.client.Close()
+	i.client = client
+	// the masters of a cluster may have changed after a failover
+	nodes, err := masterAddresses(client)
+	if err != nil {
+		return err
+	}
+	i.setNodes(nodes)
+	return nil
+}
+
+// setNodes replaces the masters scanned, the pattern is scanned again from the first master when the one being
+// scanned isn't a master anymore, as its cursor can't be used on another one
+func (i *HashIterator) setNodes(nodes []string) {
+	address := i.nodes[i.node]
+	i.nodes, i.node = nodes, 0
+	for idx, node := range nodes {
+		if node == address {
+			i.node = idx
+			return
+		}
+	}
+	i.cursor, i.skipUntil = "0", ""
+}
+
+// parsePosition sets the snapshot state from the position, an empty position starts the snapshot from the beginning
+// while a position which isn't a snapshot position means the snapshot is done
+func (i *HashIterator) parsePosition(position opencdc.Position) error {
+	if len(position) == 0 {
+		return nil
+	}
+	if position[0] != '{' {
+		i.snapshotDone = true
+		return nil
+	}
+
+	var pos hashPosition
+	if err := json.Unmarshal(position, &pos); err != nil {
+		return fmt.Errorf("invalid position(%s): %w", string(position), err)
+	}
+	for idx, pattern := range i.patterns {
+		if pattern == pos.Match {
+			i.match, i.cursor, i.skipUntil = idx, pos.Cursor, pos.Key
+			nodes := i.nodes
+			i.nodes = []string{pos.Node}
+			i.setNodes(nodes)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid position(%s): pattern %q is not configured", string(position), pos.Match)
+}
+
+// scan reads the next batch of hash keys and adds their snapshot records to the buffer
+func (i *HashIterator) scan(ctx context.Context) error {
+	match, node, cursor := i.patterns[i.match], i.nodes[i.node], i.cursor
+	reply, scanned, err := i.scanNode(match)
+	if err != nil {
+		return fmt.Errorf("error scanning keys matching pattern(%s): %w", match, err)
+	}
+	if !scanned {
+		// the master isn't part of the cluster anymore
+		nodes, err := masterAddresses(i.client)
+		if err != nil {
+			return err
+		}
+		i.setNodes(nodes)
+		return nil
+	}
+	resp, err := redis.Values(reply, nil)
+	if err != nil {
+		return fmt.Errorf("invalid SCAN response: %w", err)
+	}
+	if len(resp) != 2 {
+		return fmt.Errorf("invalid SCAN response, expected 2 elements, got %d", len(resp))
+	}
+	next, err := redis.String(resp[0], nil)
+	if err != nil {
+		return fmt.Errorf("invalid SCAN cursor: %w", err)
+	}
+	keys, err := redis.Strings(resp[1], nil)
+	if err != nil {
+		return fmt.Errorf("invalid SCAN keys: %w", err)
+	}
+
+	keys = i.skipSnapshotted(keys)
+	// the records are buffered once the whole batch is read, so that a failed batch can be scanned again
+	records := make([]opencdc.Record, 0, len(keys))
+	for _, key := range keys {
+		value, err := hashData(i.client, key)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			// the key was removed after being scanned
+			continue
+		}
+
+		position, err := json.Marshal(hashPosition{Match: match, Node: node, Cursor: cursor, Key: key})
+		if err != nil {
+			return fmt.Errorf("error marshaling position: %w", err)
+		}
+		metadata := opencdc.Metadata{
+			"type":    "snapshot",
+			"key":     key,
+			"keyType": keyTypeHash,
+		}
+		metadata.SetCreatedAt(time.Now())
+		records = append(records, sdk.Util.Source.NewRecordSnapshot(position, metadata, opencdc.RawData(key), value))
+	}
+	i.buffer = append(i.buffer, records...)
+	i.skipUntil = ""
+
+	if next != "0" {
+		i.cursor = next
+		return nil
+	}
+	i.node, i.cursor = i.node+1, "0"
+	if i.node < len(i.nodes) {
+		return nil
+	}
+	i.match, i.node = i.match+1, 0
+	if i.match == len(i.patterns) {
+		sdk.Logger(ctx).Info().Int("patterns", len(i.patterns)).Msg("hash snapshot done, capturing the changes")
+		i.snapshotDone = true
+	}
+	return nil
+}
+
+// scanNode runs SCAN on the master being scanned, it returns false when the master isn't part of the cluster anymore
+func (i *HashIterator) scanNode(match string) (interface{}, bool, error) {
+	args := []interface{}{i.cursor, "MATCH", match, "TYPE", keyTypeHash, "COUNT", scanCount}
+	clusterConn, ok := i.client.(*cluster.Conn)
+	if !ok {
+		reply, err := i.client.Do("SCAN", args...)
+		return reply, true, err
+	}
+
+	var reply interface{}
+	scanned := false
+	err := clusterConn.ForEachMaster(func(address string, node redis.Conn) error {
+		if address != i.nodes[i.node] {
+			return nil
+		}
+		var err error
+		reply, err = node.Do("SCAN", args...)
+		scanned = true
+		return err
+	})
+	return reply, scanned, err
+}
+
+// masterAddresses returns the addresses of the masters of a cluster, which are scanned in turn,
+// or a single empty address for the other connections
+func masterAddresses(client redis.Conn) ([]string, error) {
+	clusterConn, ok := client.(*cluster.Conn)
+	if !ok {
+		return []string{""}, nil
+	}
+	addresses := make([]string, 0)
+	err := clusterConn.ForEachMaster(func(address string, _ redis.Conn) error {
+		addresses = append(addresses, address)
+		return nil
+	})
+	if err == nil && len(addresses) == 0 {
+		err = errors.New("no master found in the cluster")
+	}
+	return addresses, err
+}
+
+// skipSnapshotted drops the keys of the first batch scanned after restarting which were snapshotted before,
+// the batch is read again in full when the last snapshotted key isn't returned
+func (i *HashIterator) skipSnapshotted(keys []string) []string {
+	if i.skipUntil == "" {
+		return keys
+	}
+	for idx, key := range keys {
+		if key == i.skipUntil {
+			return keys[idx+1:]
+		}
+	}
+	return keys
+}
@@ -0,0 +1,193 @@
+// Copyright © 2022 Meroxa, Inc. & Gophers Lab Technologies Pvt. Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/conduitio-labs/conduit-connector-redis/config"
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func newHashIterator(t *testing.T, ctx context.Context, mr *miniredis.Miniredis, position opencdc.Position) *HashIterator {
+	t.Helper()
+	conns := make([]redis.Conn, 3)
+	for i := range conns {
+		conn, err := redis.Dial("tcp", mr.Addr())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns[i] = conn
+	}
+
+	res, err := NewHashIterator(ctx, conns[0], conns[1], conns[2], nil,
+		config.Config{RedisKey: "user:*", Mode: config.ModeHash}, position)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		assert.NoError(t, res.Stop())
+	})
+	return res
+}
+
+func TestHashIterator(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	for i := 0; i < 3; i++ {
+		mr.HSet(fmt.Sprintf("user:%d", i), "name", fmt.Sprintf("user%d", i))
+	}
+	assert.NoError(t, mr.Set("user:string", "not a hash"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res := newHashIterator(t, ctx, mr, nil)
+
+	snapshot := make(map[string]opencdc.Data)
+	var last opencdc.Position
+	for i := 0; i < 3; i++ {
+		assert.True(t, res.HasNext())
+		rec, err := res.Next(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, opencdc.OperationSnapshot, rec.Operation)
+		snapshot[string(rec.Key.Bytes())] = rec.Payload.After
+		last = rec.Position
+	}
+	assert.Equal(t, map[string]opencdc.Data{
+		"user:0": opencdc.StructuredData{"name": "user0"},
+		"user:1": opencdc.StructuredData{"name": "user1"},
+		"user:2": opencdc.StructuredData{"name": "user2"},
+	}, snapshot)
+	assert.True(t, res.snapshotDone)
+	assert.JSONEq(t, `{"match":"user:*","cursor":"0","key":"user:2"}`, string(last))
+
+	// the changes are captured once the snapshot is done
+	assert.Eventually(t, func() bool {
+		return mr.PubSubNumPat() == 1
+	}, time.Second, 10*time.Millisecond)
+	mr.HSet("user:1", "name", "jane")
+	mr.Publish("__keyspace@0__:user:1", "hset")
+	assert.Eventually(t, res.HasNext, time.Second, 10*time.Millisecond)
+	rec, err := res.Next(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, opencdc.OperationUpdate, rec.Operation)
+	assert.Equal(t, opencdc.StructuredData{"name": "jane"}, rec.Payload.After)
+
+	// changes to keys of other types are skipped
+	mr.Publish("__keyspace@0__:user:string", "set")
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, res.HasNext())
+}
+
+func TestHashIterator_Resume(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	for i := 0; i < 3; i++ {
+		mr.HSet(fmt.Sprintf("user:%d", i), "name", fmt.Sprintf("user%d", i))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tests := []struct {
+		name     string
+		position opencdc.Position
+		want     []string
+	}{
+		{
+			name:     "resume snapshot",
+			position: opencdc.Position(`{"match":"user:*","cursor":"0","key":"user:0"}`),
+			want:     []string{"user:1", "user:2"},
+		}, {
+			name:     "snapshot done",
+			position: opencdc.Position("__keyspace@0__:user:0_1"),
+			want:     nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := newHashIterator(t, ctx, mr, tt.position)
+
+			var keys []string
+			for !res.snapshotDone || len(res.buffer) > 0 {
+				rec, err := res.Next(ctx)
+				assert.NoError(t, err)
+				keys = append(keys, string(rec.Key.Bytes()))
+			}
+			assert.Equal(t, tt.want, keys)
+		})
+	}
+}
+
+func TestHashIterator_InvalidPosition(t *testing.T) {
+	res := &HashIterator{patterns: []string{"user:*"}}
+	err := res.parsePosition(opencdc.Position(`{"match":"order:*","cursor":"0","key":"order:1"}`))
+	assert.EqualError(t, err, `invalid position({"match":"order:*","cursor":"0","key":"order:1"}): pattern "order:*" is not configured`)
+}
+
+func TestHashIterator_Cluster(t *testing.T) {
+	mr := miniredis.RunT(t)
+	mr.HSet("user:1", "name", "john")
+	// miniredis replies to CLUSTER SHARDS as a single node owning all the slots
+	cfg := config.Config{RedisKey: "user:*", Mode: config.ModeHash, ClusterAddresses: []string{mr.Addr()}}
+	conns := make([]redis.Conn, 3)
+	for i := range conns {
+		conn, err := cfg.Dial(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns[i] = conn
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res, err := NewHashIterator(ctx, conns[0], conns[1], conns[2], nil, cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		assert.NoError(t, res.Stop())
+	}()
+
+	// the position holds the master being scanned
+	rec, err := res.Next(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, opencdc.OperationSnapshot, rec.Operation)
+	assert.JSONEq(t, fmt.Sprintf(`{"match":"user:*","node":%q,"cursor":"0","key":"user:1"}`, mr.Addr()), string(rec.Position))
+
+	// the notifications are subscribed to on every master
+	assert.Eventually(t, func() bool {
+		return mr.PubSubNumPat() == 1
+	}, time.Second, 10*time.Millisecond)
+	mr.HSet("user:1", "name", "jane")
+	mr.Publish("__keyspace@0__:user:1", "hset")
+	assert.Eventually(t, res.HasNext, time.Second, 10*time.Millisecond)
+	rec, err = res.Next(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, opencdc.OperationUpdate, rec.Operation)
+	assert.Equal(t, opencdc.StructuredData{"name": "jane"}, rec.Payload.After)
+}
@@ -0,0 +1,322 @@
+// Copyright © 2022 Meroxa, Inc. & Gophers Lab Technologies Pvt. Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/conduitio-labs/conduit-connector-redis/cluster"
+	"github.com/conduitio-labs/conduit-connector-redis/config"
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	keyTypeString = "string"
+	keyTypeHash   = "hash"
+	keyTypeList   = "list"
+	keyTypeSet    = "set"
+	keyTypeZSet   = "zset"
+
+	// keyspaceEventNew is sent before the event of the command creating a key, when the "n" flag is enabled
+	keyspaceEventNew = "new"
+)
+
+// keyspaceDeleteEvents are the keyspace events sent when a key is removed
+var keyspaceDeleteEvents = map[string]bool{
+	"del":         true,
+	"expired":     true,
+	"evicted":     true,
+	"rename_from": true,
+	"move_from":   true,
+}
+
+// NewKeyspaceIterator creates a new instance of pubsub iterator subscribed to the keyspace notifications of the
+// configured keys, or patterns. The current value of a key is fetched with client each time it is changed.
+// dial is used to reconnect after a connection loss.
+func NewKeyspaceIterator(ctx context.Context, subClient, client redis.Conn, dial Dialer, cfg config.Config) (*PubSubIterator, error) {
+	return newKeyspaceIterator(ctx, subClient, client, dial, cfg, false)
+}
+
+// newKeyspaceIterator creates the keyspace iterator, restricted to hash keys emitted as structured data when hashes is true
+func newKeyspaceIterator(
+	ctx context.Context,
+	subClient, client redis.Conn,
+	dial Dialer,
+	cfg config.Config,
+	hashes bool,
+) (*PubSubIterator, error) {
+	if err := enableNotifications(client, cfg.NotifyKeyspaceEvents); err != nil {
+		return nil, err
+	}
+	subClient, err := subscriptionConn(subClient)
+	if err != nil {
+		return nil, err
+	}
+	subDial := dial
+	if dial != nil {
+		subDial = func(ctx context.Context) (redis.Conn, error) {
+			conn, err := dial(ctx)
+			if err != nil {
+				return nil, err
+			}
+			subConn, err := subscriptionConn(conn)
+			if err != nil {
+				_ = conn.Close()
+				return nil, err
+			}
+			return subConn, nil
+		}
+	}
+
+	prefix := fmt.Sprintf("__keyspace@%d__:", cfg.Database)
+	keys := cfg.Keys()
+	channels := make([]string, 0, len(keys))
+	for _, key := range keys {
+		channels = append(channels, prefix+key)
+	}
+
+	return newPubSubIterator(ctx, subClient, subDial, cfg, channels, false, &keyspaceHandler{
+		client:      client,
+		reconnector: newReconnector(dial, cfg),
+		events:      cfg.NotifyKeyspaceEvents,
+		prefix:      prefix,
+		created:     make(map[string]bool),
+		hashes:      hashes,
+	})
+}
+
+// subscriptionConn returns the connection the keyspace notifications are subscribed to with, in a cluster the
+// notifications are published by the master owning the key, they are subscribed to on every master
+func subscriptionConn(conn redis.Conn) (redis.Conn, error) {
+	clusterConn, ok := conn.(*cluster.Conn)
+	if !ok {
+		return conn, nil
+	}
+	return cluster.NewSubscriptionConn(clusterConn)
+}
+
+// enableNotifications enables the keyspace notifications with CONFIG SET, on each master of a cluster,
+// unless events is empty
+func enableNotifications(client redis.Conn, events string) error {
+	if events == "" {
+		return nil
+	}
+	enable := func(conn redis.Conn) error {
+		if _, err := conn.Do("CONFIG", "SET", "notify-keyspace-events", events); err != nil {
+			return fmt.Errorf("error enabling keyspace notifications: %w", err)
+		}
+		return nil
+	}
+	clusterConn, ok := client.(*cluster.Conn)
+	if !ok {
+		return enable(client)
+	}
+	return clusterConn.ForEachMaster(func(_ string, node redis.Conn) error {
+		return enable(node)
+	})
+}
+
+// keyspaceHandler creates a record with the current value of the key for each keyspace notification
+type keyspaceHandler struct {
+	client redis.Conn
+	// reconnector replaces client after a connection loss, events are the notifications enabled again
+	// on the new connection, as the master may have changed
+	reconnector reconnector
+	events      string
+	prefix      string
+	// created holds the keys for which a "new" event was received, the next event of the key is a create
+	created map[string]bool
+	// hashes restricts the records to hash keys, whose fields are used as structured payload
+	hashes bool
+}
+
+func (h *keyspaceHandler) toRecord(ctx context.Context, msg redis.Message) (opencdc.Record, bool, error) {
+	key := strings.TrimPrefix(msg.Channel, h.prefix)
+	event := string(msg.Data)
+	if event == keyspaceEventNew {
+		h.created[key] = true
+		return opencdc.Record{}, false, nil
+	}
+
+	metadata := opencdc.Metadata{
+		"type":    "keyspace",
+		"channel": msg.Channel,
+		"key":     key,
+		"command": event,
+	}
+	metadata.SetCreatedAt(time.Now())
+	position := []byte(fmt.Sprintf("%s_%d", msg.Channel, time.Now().UnixNano()))
+
+	if keyspaceDeleteEvents[event] {
+		delete(h.created, key)
+		return sdk.Util.Source.NewRecordDelete(position, metadata, opencdc.RawData(key), nil), true, nil
+	}
+
+	fetch := fetchValue
+	if h.hashes {
+		fetch = fetchHash
+	}
+	keyType, value, err := fetch(h.client, key)
+	if err != nil && h.reconnector.canReconnect(err) {
+		// the value is fetched again on a new connection, the subscribed one is re-established once its loss is noticed
+		client, rerr := h.reconnector.reconnect(ctx, err, ctx.Done())
+		if rerr != nil {
+			return opencdc.Record{}, false, rerr
+		}
+		if err := h.replaceClient(client); err != nil {
+			return opencdc.Record{}, false, err
+		}
+		keyType, value, err = fetch(h.client, key)
+	}
+	if err != nil {
+		return opencdc.Record{}, false, err
+	}
+	if keyType == keyTypeNone || (h.hashes && keyType != keyTypeHash) {
+		// the key was removed before its value could be fetched, its removal is reported by the following event,
+		// or it isn't a hash when only hashes are captured
+		return opencdc.Record{}, false, nil
+	}
+	metadata["keyType"] = keyType
+
+	if h.created[key] {
+		delete(h.created, key)
+		return sdk.Util.Source.NewRecordCreate(position, metadata, opencdc.RawData(key), value), true, nil
+	}
+	return sdk.Util.Source.NewRecordUpdate(position, metadata, opencdc.RawData(key), nil, value), true, nil
+}
+
+// ack is a no-op, as the keyspace notifications can't be delivered again
+func (h *keyspaceHandler) ack(context.Context, opencdc.Position) error {
+	return nil
+}
+
+func (h *keyspaceHandler) close() error {
+	return h.client.Close()
+}
+
+// reconnect replaces the value connection once the subscribed one was re-established
+func (h *keyspaceHandler) reconnect(ctx context.Context) error {
+	client, err := h.reconnector.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("error reconnecting the value connection: %w", err)
+	}
+	return h.replaceClient(client)
+}
+
+// replaceClient replaces the value connection and enables the keyspace notifications again,
+// as the new connection may be to a new master
+func (h *keyspaceHandler) replaceClient(client redis.Conn) error {
+	// the connection is likely broken already, the error closing it doesn't matter
+	_ = h.client.Close()
+	h.client = client
+	return enableNotifications(client, h.events)
+}
+
+// fetchValue returns the type and the current value of the key. Strings are returned as is, while the other types
+// are encoded as JSON: hashes as objects, lists and sets as arrays, sorted sets as objects of member to score and
+// streams as the fields of their last entry. The value is nil for the types which can't be read.
+func fetchValue(client redis.Conn, key string) (string, opencdc.Data, error) {
+	keyType, err := redis.String(client.Do("TYPE", key))
+	if err != nil {
+		return "", nil, fmt.Errorf("error fetching type of key(%s): %w", key, err)
+	}
+
+	var value interface{}
+	switch keyType {
+	case keyTypeNone:
+		return keyType, nil, nil
+	case keyTypeString:
+		b, err := redis.Bytes(client.Do("GET", key))
+		if err != nil && err != redis.ErrNil {
+			return "", nil, fmt.Errorf("error fetching value of key(%s): %w", key, err)
+		}
+		return keyType, opencdc.RawData(b), nil
+	case keyTypeHash:
+		value, err = redis.StringMap(client.Do("HGETALL", key))
+	case keyTypeList:
+		value, err = redis.Strings(client.Do("LRANGE", key, 0, -1))
+	case keyTypeSet:
+		value, err = redis.Strings(client.Do("SMEMBERS", key))
+	case keyTypeZSet:
+		value, err = redis.Float64Map(client.Do("ZRANGE", key, 0, -1, "WITHSCORES"))
+	case keyTypeStream:
+		value, err = lastStreamEntry(client, key)
+	default:
+		return keyType, nil, nil
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("error fetching value of key(%s): %w", key, err)
+	}
+
+	payload, err := json.Marshal(value)
+	if err != nil {
+		return "", nil, fmt.Errorf("error marshaling the value of key(%s): %w", key, err)
+	}
+	return keyType, opencdc.RawData(payload), nil
+}
+
+// fetchHash returns the type of the key and, for hashes, its fields as structured data.
+// The value is nil for the other types.
+func fetchHash(client redis.Conn, key string) (string, opencdc.Data, error) {
+	keyType, err := redis.String(client.Do("TYPE", key))
+	if err != nil {
+		return "", nil, fmt.Errorf("error fetching type of key(%s): %w", key, err)
+	}
+	if keyType != keyTypeHash {
+		return keyType, nil, nil
+	}
+
+	value, err := hashData(client, key)
+	if err != nil {
+		return "", nil, err
+	}
+	return keyType, value, nil
+}
+
+// hashData returns the fields of the hash as structured data, or nil if the hash doesn't exist
+func hashData(client redis.Conn, key string) (opencdc.Data, error) {
+	fields, err := redis.StringMap(client.Do("HGETALL", key))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching fields of hash(%s): %w", key, err)
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	data := make(opencdc.StructuredData, len(fields))
+	for field, value := range fields {
+		data[field] = value
+	}
+	return data, nil
+}
+
+// lastStreamEntry returns the fields of the last entry of the stream
+func lastStreamEntry(client redis.Conn, key string) (map[string]string, error) {
+	entries, err := redis.Values(client.Do("XREVRANGE", key, "+", "-", "COUNT", 1))
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	_, fieldList, err := parsePositionData(entries[0])
+	if err != nil {
+		return nil, err
+	}
+	return arrInterfaceToMap(fieldList)
+}
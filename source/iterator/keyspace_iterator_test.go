@@ -0,0 +1,143 @@
+// Copyright © 2022 Meroxa, Inc. & Gophers Lab Technologies Pvt. Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/conduitio-labs/conduit-connector-redis/config"
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewKeyspaceIterator(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	subConn, err := redis.Dial("tcp", mr.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := redis.Dial("tcp", mr.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := NewKeyspaceIterator(ctx, subConn, conn, nil, config.Config{RedisKey: "user:*", Mode: config.ModeKeyspace})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"__keyspace@0__:user:*"}, res.patterns)
+	defer func() {
+		assert.NoError(t, res.Stop())
+	}()
+	assert.Eventually(t, func() bool {
+		return mr.PubSubNumPat() == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// miniredis doesn't send keyspace notifications, they are published the way redis does
+	// each change is read before the next one, as the value is fetched once the notification is received
+	tests := []struct {
+		change    func()
+		events    []string
+		operation opencdc.Operation
+		payload   opencdc.Data
+	}{
+		{
+			change:    func() { mr.HSet("user:1", "name", "john") },
+			events:    []string{"new", "hset"},
+			operation: opencdc.OperationCreate,
+			payload:   opencdc.RawData(`{"name":"john"}`),
+		}, {
+			change:    func() { mr.HSet("user:1", "name", "jane") },
+			events:    []string{"hset"},
+			operation: opencdc.OperationUpdate,
+			payload:   opencdc.RawData(`{"name":"jane"}`),
+		}, {
+			change:    func() { mr.Del("user:1") },
+			events:    []string{"del"},
+			operation: opencdc.OperationDelete,
+			payload:   nil,
+		},
+	}
+	for _, tt := range tests {
+		tt.change()
+		for _, event := range tt.events {
+			mr.Publish("__keyspace@0__:user:1", event)
+		}
+
+		assert.Eventually(t, res.HasNext, time.Second, 10*time.Millisecond)
+		rec, err := res.Next(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.operation, rec.Operation)
+		assert.Equal(t, tt.events[len(tt.events)-1], rec.Metadata["command"])
+		assert.Equal(t, "user:1", rec.Metadata["key"])
+		assert.Equal(t, opencdc.RawData("user:1"), rec.Key)
+		assert.Equal(t, tt.payload, rec.Payload.After)
+	}
+}
+
+func TestFetchValue(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	conn, err := redis.Dial("tcp", mr.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	assert.NoError(t, mr.Set("string", "value"))
+	mr.HSet("hash", "field", "value")
+	_, err = mr.Push("list", "a", "b")
+	assert.NoError(t, err)
+	_, err = mr.SetAdd("set", "a")
+	assert.NoError(t, err)
+	_, err = mr.ZAdd("zset", 1.5, "a")
+	assert.NoError(t, err)
+	_, err = conn.Do("XADD", "stream", "*", "field", "value")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		key     string
+		keyType string
+		want    opencdc.Data
+	}{
+		{key: "string", keyType: "string", want: opencdc.RawData("value")},
+		{key: "hash", keyType: "hash", want: opencdc.RawData(`{"field":"value"}`)},
+		{key: "list", keyType: "list", want: opencdc.RawData(`["a","b"]`)},
+		{key: "set", keyType: "set", want: opencdc.RawData(`["a"]`)},
+		{key: "zset", keyType: "zset", want: opencdc.RawData(`{"a":1.5}`)},
+		{key: "stream", keyType: "stream", want: opencdc.RawData(`{"field":"value"}`)},
+		{key: "missing", keyType: "none", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			keyType, value, err := fetchValue(conn, tt.key)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.keyType, keyType)
+			assert.Equal(t, tt.want, value)
+		})
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright © 2022 Meroxa, Inc. & Gophers Lab Technologies Pvt. Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/conduitio-labs/conduit-connector-redis/config"
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/gomodule/redigo/redis"
+	"gopkg.in/tomb.v2"
+)
+
+// ListIterator reads a list as a reliable queue, the items are moved from the tail of the list to the head of the
+// processing list with BLMOVE and are removed from the processing list with LREM once acked
+type ListIterator struct {
+	key           string
+	processingKey string
+	// timeout is the time BLMOVE blocks for, after which the iterator checks whether it is stopped
+	timeout time.Duration
+	// client is blocked by BLMOVE, the processing list is read and updated using ackClient
+	client    redis.Conn
+	ackClient redis.Conn
+	records   []opencdc.Record
+	// pending holds the value of the records not acked yet by their position
+	pending map[string][]byte
+	// seq makes the positions unique, it starts at the time the iterator is created
+	seq int64
+	// reconnector re-establishes the connections after a connection loss, the items moved to the processing list
+	// before the loss stay in it and are emitted again on restart if their record isn't acked
+	reconnector reconnector
+	mux         *sync.Mutex
+	tomb        *tomb.Tomb
+}
+
+// NewListIterator emits the items left in the processing list by a previous run
+// and starts moving the new items of the list to the processing list. dial is used to reconnect after a connection loss.
+func NewListIterator(ctx context.Context, client, ackClient redis.Conn, dial Dialer, cfg config.Config) (*ListIterator, error) {
+	// list mode reads a single key, validated by the config
+	i := &ListIterator{
+		key:           cfg.Keys()[0],
+		processingKey: cfg.ProcessingKey,
+		timeout:       cfg.PollingPeriod,
+		client:        client,
+		ackClient:     ackClient,
+		records:       make([]opencdc.Record, 0),
+		pending:       make(map[string][]byte),
+		seq:           time.Now().UnixNano(),
+		reconnector:   newReconnector(dial, cfg),
+		mux:           &sync.Mutex{},
+	}
+
+	// the oldest items are at the tail of the processing list
+	leftovers, err := redis.ByteSlices(ackClient.Do("LRANGE", i.processingKey, 0, -1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading processing list(%s): %w", i.processingKey, err)
+	}
+	for idx := len(leftovers) - 1; idx >= 0; idx-- {
+		i.records = append(i.records, i.toRecord(leftovers[idx], true))
+	}
+	if len(leftovers) > 0 {
+		sdk.Logger(ctx).Info().
+			Int("count", len(leftovers)).
+			Str("processing_key", i.processingKey).
+			Msg("emitting the items left in the processing list")
+	}
+
+	i.tomb, _ = tomb.WithContext(ctx)
+	i.tomb.Go(i.startMover(ctx))
+
+	return i, nil
+}
+
+// HasNext returns whether there are any more records to be returned
+// or when the error is to be returned by the Next function
+func (i *ListIterator) HasNext() bool {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	return len(i.records) > 0 || !i.tomb.Alive() // if tomb is dead we return true so caller will fetch error with Next
+}
+
+// Next pops and returns the first record from records queue
+func (i *ListIterator) Next(ctx context.Context) (opencdc.Record, error) {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+
+	if len(i.records) > 0 {
+		rec := i.records[0]
+		i.records = i.records[1:]
+		return rec, nil
+	}
+	select {
+	case <-i.tomb.Dying():
+		return opencdc.Record{}, i.tomb.Err()
+	case <-ctx.Done():
+		return opencdc.Record{}, ctx.Err()
+	default:
+		return opencdc.Record{}, sdk.ErrBackoffRetry
+	}
+}
+
+// Ack removes the item of the record from the processing list, it is retried once after reconnecting when the
+// connection is lost
+func (i *ListIterator) Ack(ctx context.Context, position opencdc.Position) error {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+
+	value, ok := i.pending[string(position)]
+	if !ok {
+		return fmt.Errorf("unknown position(%s)", string(position))
+	}
+	// the item is removed starting from the tail, where the oldest items are
+	_, err := i.ackClient.Do("LREM", i.processingKey, -1, value)
+	if err != nil && i.reconnector.canReconnect(err) {
+		conn, dialErr := i.reconnector.reconnect(ctx, err, i.tomb.Dying())
+		if dialErr != nil {
+			return fmt.Errorf("error removing item from processing list(%s): %w", i.processingKey, dialErr)
+		}
+		// the connection is broken already, the error closing it doesn't matter
+		_ = i.ackClient.Close()
+		i.ackClient = conn
+		_, err = i.ackClient.Do("LREM", i.processingKey, -1, value)
+	}
+	if err != nil {
+		return fmt.Errorf("error removing item from processing list(%s): %w", i.processingKey, err)
+	}
+	delete(i.pending, string(position))
+	return nil
+}
+
+// Stop sends a kill signal to tomb and closes the connection used for acks,
+// the mover closes its own connection once its BLMOVE returns
+func (i *ListIterator) Stop() error {
+	i.tomb.Kill(errors.New("iterator stopped"))
+
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	return i.ackClient.Close()
+}
+
+// startMover returns the go routine function moving the items of the list to the processing list until the tomb
+// dies, the connection is re-established after a connection loss when enabled
+func (i *ListIterator) startMover(ctx context.Context) func() error {
+	return func() error {
+		// the client is replaced when reconnecting
+		defer func() { _ = i.client.Close() }()
+		for {
+			select {
+			case <-i.tomb.Dying():
+				return fmt.Errorf("tomb error: %w", i.tomb.Err())
+			default:
+				if err := i.move(ctx); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// move moves the next item of the list to the processing list, reconnecting after a connection loss
+func (i *ListIterator) move(ctx context.Context) error {
+	value, err := redis.Bytes(i.client.Do("BLMOVE", i.key, i.processingKey, "RIGHT", "LEFT", i.timeout.Seconds()))
+	if errors.Is(err, redis.ErrNil) {
+		// the timeout elapsed without new items
+		return nil
+	}
+	if err != nil && i.tomb.Alive() && i.reconnector.canReconnect(err) {
+		return i.reconnect(ctx, err)
+	}
+	if err != nil {
+		return fmt.Errorf("error moving item from list(%s): %w", i.key, err)
+	}
+
+	i.mux.Lock()
+	i.records = append(i.records, i.toRecord(value, false))
+	i.mux.Unlock()
+	return nil
+}
+
+// reconnect replaces the connection of the mover after a connection loss
+func (i *ListIterator) reconnect(ctx context.Context, cause error) error {
+	client, err := i.reconnector.reconnect(ctx, cause, i.tomb.Dying())
+	if err != nil {
+		return err
+	}
+	// the connection is broken already, the error closing it doesn't matter
+	_ = i.client.Close()
+	i.client = client
+	return nil
+}
+
+// toRecord creates the record of the item and keeps its value until it is acked, it has to be called with mux locked
+// unless no other goroutine is running
+func (i *ListIterator) toRecord(value []byte, redelivered bool) opencdc.Record {
+	i.seq++
+	position := fmt.Sprintf("%s_%d", i.processingKey, i.seq)
+	i.pending[position] = value
+
+	metadata := opencdc.Metadata{
+		"type":          "list",
+		"key":           i.key,
+		"processingKey": i.processingKey,
+	}
+	if redelivered {
+		metadata["redelivered"] = "true"
+	}
+	metadata.SetCreatedAt(time.Now())
+
+	return sdk.Util.Source.NewRecordCreate(
+		[]byte(position),
+		metadata,
+		opencdc.RawData(i.key),
+		opencdc.RawData(value),
+	)
+}
@@ -0,0 +1,108 @@
+// Copyright © 2022 Meroxa, Inc. & Gophers Lab Technologies Pvt. Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/conduitio-labs/conduit-connector-redis/config"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func newListIterator(t *testing.T, ctx context.Context, mr *miniredis.Miniredis) *ListIterator {
+	t.Helper()
+	client, err := redis.Dial("tcp", mr.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ackClient, err := redis.Dial("tcp", mr.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := NewListIterator(ctx, client, ackClient, nil, config.Config{
+		// the key is trimmed by the config
+		RedisKey:      " jobs ",
+		Mode:          config.ModeList,
+		ProcessingKey: "jobs:processing",
+		PollingPeriod: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestListIterator(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res := newListIterator(t, ctx, mr)
+
+	// producers push to the head of the list, the items are read from its tail
+	_, err = mr.Lpush("jobs", "job1")
+	assert.NoError(t, err)
+	_, err = mr.Lpush("jobs", "job2")
+	assert.NoError(t, err)
+
+	var values []string
+	var positions [][]byte
+	for i := 0; i < 2; i++ {
+		assert.Eventually(t, res.HasNext, time.Second, 10*time.Millisecond)
+		rec, err := res.Next(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "jobs", rec.Metadata["key"])
+		assert.Equal(t, "", rec.Metadata["redelivered"])
+		values = append(values, string(rec.Payload.After.Bytes()))
+		positions = append(positions, rec.Position)
+	}
+	assert.Equal(t, []string{"job1", "job2"}, values)
+
+	list, err := mr.List("jobs:processing")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"job2", "job1"}, list)
+	assert.False(t, mr.Exists("jobs"))
+
+	// only the acked items are removed from the processing list
+	assert.NoError(t, res.Ack(ctx, positions[0]))
+	list, err = mr.List("jobs:processing")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"job2"}, list)
+	assert.EqualError(t, res.Ack(ctx, positions[0]), "unknown position("+string(positions[0])+")")
+	assert.NoError(t, res.Stop())
+
+	// the items not acked are emitted again once restarted
+	res = newListIterator(t, ctx, mr)
+	defer func() {
+		assert.NoError(t, res.Stop())
+	}()
+	assert.True(t, res.HasNext())
+	rec, err := res.Next(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "job2", string(rec.Payload.After.Bytes()))
+	assert.Equal(t, "true", rec.Metadata["redelivered"])
+
+	assert.NoError(t, res.Ack(ctx, rec.Position))
+	assert.False(t, mr.Exists("jobs:processing"))
+}
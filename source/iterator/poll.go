@@ -0,0 +1,85 @@
+// Copyright © 2022 Meroxa, Inc. & Gophers Lab Technologies Pvt. Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"errors"
+	"time"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+	"gopkg.in/tomb.v2"
+)
+
+// pollRecords is the loop of the go routine polling for new records, poll is called each time wait fires until
+// the tomb is dying and the batches of records it returns are sent to caches, which is closed once it returns.
+// It returns the error of poll, except ErrEndReached for which it returns without error, letting flushRecords
+// return the buffered records before the tomb dies.
+func pollRecords(
+	t *tomb.Tomb,
+	wait func() <-chan time.Time,
+	poll func() ([]opencdc.Record, error),
+	caches chan<- []opencdc.Record,
+) error {
+	defer close(caches)
+	for {
+		select {
+		case <-t.Dying():
+			return t.Err()
+		case <-wait():
+			records, err := poll()
+			if errors.Is(err, ErrEndReached) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if len(records) == 0 {
+				continue
+			}
+
+			// ensure we don't fetch and keep a lot of records in memory
+			// block till flush reads current array of records
+			select {
+			case caches <- records:
+			case <-t.Dying():
+				return t.Err()
+			}
+		}
+	}
+}
+
+// flushRecords is the loop of the go routine getting the batches of records in caches and pushing them
+// into the buffer read by Next, which is closed once it returns
+func flushRecords(t *tomb.Tomb, caches <-chan []opencdc.Record, buffer chan<- opencdc.Record) error {
+	defer close(buffer)
+	for {
+		select {
+		case <-t.Dying():
+			return t.Err()
+		case cache, ok := <-caches:
+			if !ok {
+				// the polling go routine returned
+				return nil
+			}
+			for _, record := range cache {
+				select {
+				case <-t.Dying():
+					return t.Err()
+				case buffer <- record:
+				}
+			}
+		}
+	}
+}
@@ -18,9 +18,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/conduitio-labs/conduit-connector-redis/config"
 	"github.com/conduitio/conduit-commons/opencdc"
 	sdk "github.com/conduitio/conduit-connector-sdk"
 	"github.com/gomodule/redigo/redis"
@@ -28,30 +30,96 @@ import (
 )
 
 type PubSubIterator struct {
-	key     string
+	channels []string
+	patterns []string
+	// sharded is true when subscribed to sharded channels using SSUBSCRIBE
+	sharded bool
+	handler messageHandler
 	psc     *redis.PubSubConn
 	records []opencdc.Record
-	mux     *sync.Mutex
-	tomb    *tomb.Tomb
+	// bufferSize is the maximum length of records, which is unbounded when zero, overflow is the behavior
+	// when it is full and dropped the number of messages dropped since the start
+	bufferSize int
+	overflow   string
+	dropped    int
+	// dropping is true while the messages are dropped, space is signaled by Next when a record is popped
+	dropping bool
+	space    chan struct{}
+	// reconnector re-establishes the connection after a connection loss, the channels are subscribed to again
+	reconnector reconnector
+	mux         *sync.Mutex
+	tomb        *tomb.Tomb
 }
 
-// NewPubSubIterator creates a new instance of redis pubsub iterator and starts listening for new messages on channel
-func NewPubSubIterator(ctx context.Context, client redis.Conn, key string) (*PubSubIterator, error) {
-	psc := &redis.PubSubConn{Conn: client}
-	tmbWithCtx, _ := tomb.WithContext(ctx)
-	cdc := &PubSubIterator{
-		key:     key,
-		psc:     psc,
-		mux:     &sync.Mutex{},
-		tomb:    tmbWithCtx,
-		records: make([]opencdc.Record, 0),
+// messageHandler converts the messages received on the subscribed channels to records
+type messageHandler interface {
+	// toRecord returns the record for the message, or false if the message is to be skipped
+	toRecord(ctx context.Context, msg redis.Message) (opencdc.Record, bool, error)
+	// ack verifies the position of an acknowledged record
+	ack(ctx context.Context, position opencdc.Position) error
+	// close releases the resources held by the handler, once the listener is stopped
+	close() error
+}
+
+// reconnectHandler is implemented by the handlers holding a connection of their own, which is re-established
+// as well when the subscribed connection is lost
+type reconnectHandler interface {
+	reconnect(ctx context.Context) error
+}
+
+// NewPubSubIterator creates a new instance of redis pubsub iterator and starts listening for new messages
+// on the channels, and the channels matching the patterns, in the configured keys. The sequence of the records
+// continues from the one of position, the messages published before can't be delivered again.
+// dial is used to reconnect after a connection loss when enabled in cfg, and can be nil otherwise.
+func NewPubSubIterator(ctx context.Context, client redis.Conn, dial Dialer, cfg config.Config, position opencdc.Position) (*PubSubIterator, error) {
+	handler, err := newPubSubHandler(cfg, position)
+	if err != nil {
+		return nil, err
 	}
+	return newPubSubIterator(ctx, client, dial, cfg, cfg.Keys(), handler.sharded, handler)
+}
 
-	// subscribe to all the channels matching the passed pattern
-	if err := psc.Subscribe(key); err != nil {
+// newPubSubIterator subscribes to the channels, or patterns, in keys and starts the listener
+// converting the received messages to records using the handler, buffered as configured in cfg
+func newPubSubIterator(
+	ctx context.Context,
+	client redis.Conn,
+	dial Dialer,
+	cfg config.Config,
+	keys []string,
+	sharded bool,
+	handler messageHandler,
+) (*PubSubIterator, error) {
+	cdc := &PubSubIterator{
+		sharded:     sharded,
+		handler:     handler,
+		psc:         &redis.PubSubConn{Conn: client},
+		mux:         &sync.Mutex{},
+		records:     make([]opencdc.Record, 0),
+		bufferSize:  cfg.BufferSize,
+		overflow:    cfg.OverflowPolicy,
+		space:       make(chan struct{}, 1),
+		reconnector: newReconnector(dial, cfg),
+	}
+	for _, key := range keys {
+		if config.IsPattern(key) {
+			cdc.patterns = append(cdc.patterns, key)
+		} else {
+			cdc.channels = append(cdc.channels, key)
+		}
+	}
+	if len(cdc.channels)+len(cdc.patterns) == 0 {
+		return nil, errors.New("no channel to subscribe to")
+	}
+	// there are no pattern subscriptions for sharded channels
+	if cdc.sharded && len(cdc.patterns) > 0 {
+		return nil, fmt.Errorf("patterns are not supported in %s mode, got %v", config.ModeShardPubSub, cdc.patterns)
+	}
+	if err := cdc.subscribe(); err != nil {
 		return nil, err
 	}
 
+	cdc.tomb, _ = tomb.WithContext(ctx)
 	cdc.tomb.Go(cdc.startListener(ctx))
 
 	return cdc, nil
@@ -60,6 +128,8 @@ func NewPubSubIterator(ctx context.Context, client redis.Conn, key string) (*Pub
 // HasNext returns whether there are any more records to be returned
 // or when the error is to be returned by the Next function
 func (i *PubSubIterator) HasNext() bool {
+	i.mux.Lock()
+	defer i.mux.Unlock()
 	return len(i.records) > 0 || !i.tomb.Alive() // if tomb is dead we return true so caller will fetch error with Next
 }
 
@@ -74,6 +144,11 @@ func (i *PubSubIterator) Next(ctx context.Context) (opencdc.Record, error) {
 		// pop the first record from the records slice
 		rec := i.records[0]
 		i.records = i.records[1:] // remove the first record from slice
+		// wake up the listener waiting for space in the buffer
+		select {
+		case i.space <- struct{}{}:
+		default:
+		}
 		return rec, nil
 	}
 	select {
@@ -86,6 +161,11 @@ func (i *PubSubIterator) Next(ctx context.Context) (opencdc.Record, error) {
 	}
 }
 
+// Ack verifies the position of the acknowledged record, the messages are fire and forget and can't be delivered again
+func (i *PubSubIterator) Ack(ctx context.Context, position opencdc.Position) error {
+	return i.handler.ack(ctx, position)
+}
+
 // Stop sends a kill signal to tomb, converting the tomb status to Dying
 // giving go routines time to gracefully stop execution
 func (i *PubSubIterator) Stop() error {
@@ -93,34 +173,34 @@ func (i *PubSubIterator) Stop() error {
 	return nil
 }
 
-// startListener is the go routine function listening for new messages on provided channel in an infinite loop
+// startListener is the go routine function listening for new messages on provided channels in an infinite loop
 func (i *PubSubIterator) startListener(ctx context.Context) func() error {
 	return func() error {
 		for {
 			select {
 			case <-i.tomb.Dying():
+				if err := i.handler.close(); err != nil {
+					return fmt.Errorf("error closing the message handler: %w", err)
+				}
 				if err := i.psc.Close(); err != nil {
 					return fmt.Errorf("error closing the pubsub connection: %w", err)
 				}
 				return fmt.Errorf("tomb error: %w", i.tomb.Err())
 			default:
-				switch n := i.psc.Receive().(type) {
+				switch n := i.receive().(type) {
 				case redis.Message:
-					metadata := opencdc.Metadata{
-						"type":    "message",
-						"channel": n.Channel,
+					// the context is cancelled once the iterator is stopped, the handler may reconnect while handling the message
+					rec, ok, err := i.handler.toRecord(i.tomb.Context(ctx), n)
+					if err != nil {
+						return err
+					}
+					if !ok {
+						continue
+					}
+
+					if err := i.push(ctx, rec); err != nil {
+						return err
 					}
-					metadata.SetCreatedAt(time.Now())
-
-					// acquire lock before appending the new records to records slice, to avoid race between Next() and append
-					i.mux.Lock()
-					i.records = append(i.records, sdk.Util.Source.NewRecordCreate(
-						[]byte(fmt.Sprintf("%s_%d", n.Channel, time.Now().UnixNano())),
-						metadata,
-						opencdc.RawData(n.Channel),
-						opencdc.RawData(n.Data),
-					))
-					i.mux.Unlock()
 				case redis.Subscription:
 					// this message is only received at time of successful subscription/unsubscription
 					sdk.Logger(i.tomb.Context(ctx)).Trace().
@@ -132,7 +212,12 @@ func (i *PubSubIterator) startListener(ctx context.Context) func() error {
 					sdk.Logger(i.tomb.Context(ctx)).Trace().
 						Msg("pong message received")
 				case error:
-					return n
+					if !i.tomb.Alive() || !i.reconnector.canReconnect(n) {
+						return n
+					}
+					if err := i.resubscribe(ctx, n); err != nil {
+						return err
+					}
 				default:
 					// There can only be 4 type of messages, if in future a new message type is added log unknown type error
 					// So we can rectify it ASAP, instead of it being buried in lower level logs
@@ -143,3 +228,258 @@ func (i *PubSubIterator) startListener(ctx context.Context) func() error {
 		}
 	}
 }
+
+// push appends the record to the records slice, handling a full buffer according to the overflow policy
+func (i *PubSubIterator) push(ctx context.Context, rec opencdc.Record) error {
+	// acquire lock before appending the new records to records slice, to avoid race between Next() and append
+	i.mux.Lock()
+	defer i.mux.Unlock()
+
+	if i.bufferSize == 0 {
+		i.records = append(i.records, rec)
+		return nil
+	}
+
+	full := len(i.records) >= i.bufferSize
+	for full && i.overflow == config.OverflowBlock {
+		// the messages are kept in the connection until a record is read
+		i.mux.Unlock()
+		select {
+		case <-i.space:
+		case <-i.tomb.Dying():
+			// the listener stops and closes the connection on its next iteration
+			i.mux.Lock()
+			return nil
+		}
+		i.mux.Lock()
+		full = len(i.records) >= i.bufferSize
+	}
+
+	switch {
+	case !full:
+		if i.dropping {
+			sdk.Logger(ctx).Info().Int("dropped", i.dropped).Msg("buffer has space again, stopped dropping messages")
+			i.dropping = false
+		}
+	case i.overflow == config.OverflowFail:
+		return fmt.Errorf("buffer of %d records is full", i.bufferSize)
+	case i.overflow == config.OverflowDropOldest:
+		i.records = i.records[1:]
+		i.drop(ctx)
+	case i.overflow == config.OverflowDropNewest:
+		i.drop(ctx)
+		return nil
+	}
+
+	rec.Metadata["dropped"] = strconv.Itoa(i.dropped)
+	i.records = append(i.records, rec)
+	return nil
+}
+
+// drop counts a dropped message, logging when the buffer starts dropping messages
+func (i *PubSubIterator) drop(ctx context.Context) {
+	i.dropped++
+	if !i.dropping {
+		sdk.Logger(ctx).Warn().
+			Int("buffer_size", i.bufferSize).
+			Str("overflow_policy", i.overflow).
+			Int("dropped", i.dropped).
+			Msg("buffer is full, dropping messages")
+		i.dropping = true
+	}
+}
+
+// pubSubHandler creates a record for each message received on the channels, with the message as payload,
+// or decodes the record in the message when decode is set. The records are numbered with a sequence, which is
+// part of their position along with the instance id, to verify the acks are received in order.
+type pubSubHandler struct {
+	sharded  bool
+	decode   bool
+	instance string
+	// sequence is the sequence of the last message received, it is only used by the listener go routine
+	sequence uint64
+	// acked is the sequence of the last record acked, it is only used by Ack
+	acked uint64
+}
+
+// newPubSubHandler returns the handler of the messages received on the channels. A new instance id is generated each
+// time the connector is opened, as the records emitted after the last acked position may have used the following
+// sequences already. The sequence continues from that position, so the positions are ordered across restarts.
+func newPubSubHandler(cfg config.Config, position opencdc.Position) (*pubSubHandler, error) {
+	pos, _, err := parsePubSubPosition(position)
+	if err != nil {
+		return nil, err
+	}
+	return &pubSubHandler{
+		sharded:  cfg.Mode == config.ModeShardPubSub,
+		decode:   cfg.Encoding == config.EncodingOpenCDC,
+		instance: newInstanceID(),
+		sequence: pos.Sequence,
+		acked:    pos.Sequence,
+	}, nil
+}
+
+func (h *pubSubHandler) toRecord(_ context.Context, msg redis.Message) (opencdc.Record, bool, error) {
+	h.sequence++
+	position, err := pubSubPosition{Instance: h.instance, Channel: msg.Channel, Sequence: h.sequence}.toPosition()
+	if err != nil {
+		return opencdc.Record{}, false, err
+	}
+	if h.decode {
+		rec, err := decodeRecord(msg.Data, position)
+		if err != nil {
+			return opencdc.Record{}, false, fmt.Errorf("error decoding message from channel(%s): %w", msg.Channel, err)
+		}
+		// the decoded record is kept as it was written, except for its position and the sequence it is part of
+		h.setSequence(rec.Metadata)
+		return rec, true, nil
+	}
+
+	metadata := opencdc.Metadata{
+		"type":    "message",
+		"channel": msg.Channel,
+	}
+	h.setSequence(metadata)
+	switch {
+	case msg.Pattern != "":
+		// message received on a channel matching a pattern subscription
+		metadata["type"] = "pmessage"
+		metadata["pattern"] = msg.Pattern
+	case h.sharded:
+		metadata["type"] = "smessage"
+	}
+	metadata.SetCreatedAt(time.Now())
+
+	return sdk.Util.Source.NewRecordCreate(
+		position,
+		metadata,
+		opencdc.RawData(msg.Channel),
+		opencdc.RawData(msg.Data),
+	), true, nil
+}
+
+// setSequence sets the instance id and the sequence of the last message received in the metadata
+func (h *pubSubHandler) setSequence(metadata opencdc.Metadata) {
+	metadata["instance"] = h.instance
+	metadata["sequence"] = strconv.FormatUint(h.sequence, 10)
+}
+
+// ack verifies that the records of this instance are acked in the order of their sequence
+func (h *pubSubHandler) ack(ctx context.Context, position opencdc.Position) error {
+	pos, ok, err := parsePubSubPosition(position)
+	if err != nil {
+		return err
+	}
+	if !ok || pos.Instance != h.instance {
+		sdk.Logger(ctx).Warn().Str("position", string(position)).Msg("ack received for a record of another connector instance")
+		return nil
+	}
+	if pos.Sequence <= h.acked {
+		return fmt.Errorf("ack for sequence %d received out of order, sequence %d was already acked", pos.Sequence, h.acked)
+	}
+	h.acked = pos.Sequence
+	return nil
+}
+
+func (h *pubSubHandler) close() error {
+	return nil
+}
+
+// subscribe subscribes to the channels, or sharded channels, and the patterns of the iterator
+func (i *PubSubIterator) subscribe() error {
+	if i.sharded {
+		return i.ssubscribe()
+	}
+	if len(i.channels) > 0 {
+		if err := i.psc.Subscribe(redis.Args{}.AddFlat(i.channels)...); err != nil {
+			return err
+		}
+	}
+	// subscribe to all the channels matching the passed patterns
+	if len(i.patterns) > 0 {
+		if err := i.psc.PSubscribe(redis.Args{}.AddFlat(i.patterns)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resubscribe replaces the lost connection with a new one and subscribes to the channels again,
+// the messages published while disconnected are lost
+func (i *PubSubIterator) resubscribe(ctx context.Context, cause error) error {
+	// the connection is broken already, the error closing it doesn't matter
+	_ = i.psc.Close()
+
+	conn, err := i.reconnector.reconnect(ctx, cause, i.tomb.Dying())
+	if err != nil {
+		return err
+	}
+	i.psc = &redis.PubSubConn{Conn: conn}
+	if handler, ok := i.handler.(reconnectHandler); ok {
+		if err := handler.reconnect(ctx); err != nil {
+			return err
+		}
+	}
+	if err := i.subscribe(); err != nil {
+		return fmt.Errorf("error subscribing again after reconnecting: %w", err)
+	}
+	sdk.Logger(ctx).Info().
+		Strs("channels", i.channels).
+		Strs("patterns", i.patterns).
+		Msg("subscribed again after reconnecting")
+	return nil
+}
+
+// ssubscribe subscribes to the sharded channels using SSUBSCRIBE,
+// redis.PubSubConn doesn't provide a method for it
+func (i *PubSubIterator) ssubscribe() error {
+	if err := i.psc.Conn.Send("SSUBSCRIBE", redis.Args{}.AddFlat(i.channels)...); err != nil {
+		return err
+	}
+	return i.psc.Conn.Flush()
+}
+
+// receive returns the next message received on the subscribed channels, which is one of
+// redis.Message, redis.Subscription, redis.Pong or error, the same way as redis.PubSubConn.Receive
+func (i *PubSubIterator) receive() interface{} {
+	if !i.sharded {
+		return i.psc.Receive()
+	}
+	return parseShardedReply(i.psc.Conn.Receive())
+}
+
+// parseShardedReply parses the push replies of sharded channels, which redis.PubSubConn reports as unknown notifications
+func parseShardedReply(replyArg interface{}, errArg error) interface{} {
+	reply, err := redis.Values(replyArg, errArg)
+	if err != nil {
+		return err
+	}
+
+	var kind string
+	reply, err = redis.Scan(reply, &kind)
+	if err != nil {
+		return err
+	}
+
+	switch kind {
+	case "smessage":
+		var m redis.Message
+		if _, err := redis.Scan(reply, &m.Channel, &m.Data); err != nil {
+			return err
+		}
+		return m
+	case "ssubscribe", "sunsubscribe":
+		s := redis.Subscription{Kind: kind}
+		if _, err := redis.Scan(reply, &s.Channel, &s.Count); err != nil {
+			return err
+		}
+		return s
+	case "pong":
+		var p redis.Pong
+		if _, err := redis.Scan(reply, &p.Data); err != nil {
+			return err
+		}
+		return p
+	}
+	return fmt.Errorf("unknown sharded pubsub notification %q", kind)
+}
@@ -16,11 +16,15 @@ package iterator
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
+	"github.com/conduitio-labs/conduit-connector-redis/config"
 	"github.com/conduitio/conduit-commons/opencdc"
 	sdk "github.com/conduitio/conduit-connector-sdk"
 	"github.com/gomodule/redigo/redis"
@@ -47,7 +51,7 @@ func TestHasNext(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			var cdc = PubSubIterator{records: tt.records, tomb: &tomb.Tomb{}}
+			var cdc = PubSubIterator{records: tt.records, tomb: &tomb.Tomb{}, mux: &sync.Mutex{}}
 			res := cdc.HasNext()
 			assert.Equal(t, res, tt.response, tt.name)
 		})
@@ -86,14 +90,78 @@ func TestNext(t *testing.T) {
 	assert.EqualError(t, err, "context canceled")
 }
 
+func TestPubSubIterator_Push(t *testing.T) {
+	rec := func(msg string) opencdc.Record {
+		return opencdc.Record{Metadata: opencdc.Metadata{}, Payload: opencdc.Change{After: opencdc.RawData(msg)}}
+	}
+	tests := []struct {
+		name     string
+		overflow string
+		want     []string
+		dropped  string
+		err      string
+	}{
+		{name: "drop oldest", overflow: config.OverflowDropOldest, want: []string{"2", "3"}, dropped: "1"},
+		{name: "drop newest", overflow: config.OverflowDropNewest, want: []string{"1", "2"}, dropped: "0"},
+		{name: "fail", overflow: config.OverflowFail, want: []string{"1", "2"}, dropped: "0", err: "buffer of 2 records is full"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cdc := PubSubIterator{bufferSize: 2, overflow: tt.overflow, tomb: &tomb.Tomb{}, mux: &sync.Mutex{}}
+			for _, msg := range []string{"1", "2"} {
+				assert.NoError(t, cdc.push(context.Background(), rec(msg)))
+			}
+			err := cdc.push(context.Background(), rec("3"))
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, 1, cdc.dropped)
+			}
+
+			got := make([]string, 0, len(cdc.records))
+			for _, r := range cdc.records {
+				got = append(got, string(r.Payload.After.Bytes()))
+			}
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.dropped, cdc.records[len(cdc.records)-1].Metadata["dropped"])
+		})
+	}
+}
+
+func TestPubSubIterator_PushBlock(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	cdc := PubSubIterator{bufferSize: 1, overflow: config.OverflowBlock, tomb: &tomb.Tomb{}, mux: &sync.Mutex{}, space: make(chan struct{}, 1)}
+	assert.NoError(t, cdc.push(ctx, opencdc.Record{Metadata: opencdc.Metadata{}, Position: opencdc.Position("1")}))
+
+	pushed := make(chan error)
+	go func() {
+		pushed <- cdc.push(ctx, opencdc.Record{Metadata: opencdc.Metadata{}, Position: opencdc.Position("2")})
+	}()
+	select {
+	case <-pushed:
+		t.Fatal("push didn't block on a full buffer")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	rec, err := cdc.Next(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, opencdc.Position("1"), rec.Position)
+	assert.NoError(t, <-pushed)
+	rec, err = cdc.Next(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, opencdc.Position("2"), rec.Position)
+}
+
 func TestNewCDCIterator(t *testing.T) {
 	redisChannel := "subchannel"
 	conn := redigomock.NewConn()
 	response := PubSubIterator{
-		key:     redisChannel,
-		psc:     &redis.PubSubConn{Conn: conn},
-		records: []opencdc.Record{},
-		mux:     &sync.Mutex{},
+		channels: []string{redisChannel},
+		psc:      &redis.PubSubConn{Conn: conn},
+		records:  []opencdc.Record{},
+		mux:      &sync.Mutex{},
 	}
 
 	conn.Command("SUBSCRIBE", redisChannel).Expect([]interface{}{
@@ -114,10 +182,10 @@ func TestNewCDCIterator(t *testing.T) {
 			message,
 		})
 	}
-	res, err := NewPubSubIterator(context.Background(), conn, redisChannel)
+	res, err := NewPubSubIterator(context.Background(), conn, nil, config.Config{RedisKey: redisChannel}, nil)
 	assert.Nil(t, err)
 	assert.NotNil(t, res)
-	assert.Equal(t, response.key, res.key)
+	assert.Equal(t, response.channels, res.channels)
 	assert.Equal(t, response.psc, res.psc)
 }
 
@@ -135,19 +203,19 @@ func TestNewCDCIterator_Next(t *testing.T) {
 		t.Fatal(err)
 	}
 	response := PubSubIterator{
-		key:     redisChannel,
-		psc:     &redis.PubSubConn{Conn: conn},
-		records: []opencdc.Record{},
-		mux:     &sync.Mutex{},
+		channels: []string{redisChannel},
+		psc:      &redis.PubSubConn{Conn: conn},
+		records:  []opencdc.Record{},
+		mux:      &sync.Mutex{},
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	res, err := NewPubSubIterator(ctx, conn, redisChannel)
+	res, err := NewPubSubIterator(ctx, conn, nil, config.Config{RedisKey: redisChannel}, nil)
 	assert.Nil(t, err)
 	assert.NotNil(t, res)
-	assert.Equal(t, response.key, res.key)
+	assert.Equal(t, response.channels, res.channels)
 	assert.Equal(t, response.psc, res.psc)
 	// publish is a fire and forget method, give a few ms for goroutines to start
 	// otherwise messages might be lost and tests will fail
@@ -192,3 +260,215 @@ func TestNewCDCIterator_Next(t *testing.T) {
 		}
 	}
 }
+
+func TestNewCDCIterator_Patterns(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	conn, err := redis.Dial("tcp", mr.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := NewPubSubIterator(ctx, conn, nil, config.Config{RedisKey: "orders, users:*"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"orders"}, res.channels)
+	assert.Equal(t, []string{"users:*"}, res.patterns)
+	defer func() {
+		assert.NoError(t, res.Stop())
+	}()
+
+	// publish is a fire and forget method, wait for both subscriptions to be active
+	assert.Eventually(t, func() bool {
+		return mr.PubSubNumSub("orders")["orders"] == 1 && mr.PubSubNumPat() == 1
+	}, time.Second, 10*time.Millisecond)
+	mr.Publish("orders", "order_message")
+	mr.Publish("users:1", "user_message")
+	mr.Publish("other", "other_message")
+
+	// miniredis delivers the channel and pattern messages independently, so their order isn't guaranteed
+	records := make(map[string]opencdc.Record)
+	for i := 0; i < 2; i++ {
+		assert.Eventually(t, res.HasNext, time.Second, 10*time.Millisecond)
+		rec, err := res.Next(ctx)
+		assert.NoError(t, err)
+		records[rec.Metadata["type"]] = rec
+	}
+
+	rec := records["message"]
+	assert.Equal(t, "orders", rec.Metadata["channel"])
+	assert.Equal(t, "order_message", string(rec.Payload.After.Bytes()))
+
+	rec = records["pmessage"]
+	assert.Equal(t, "users:1", rec.Metadata["channel"])
+	assert.Equal(t, "users:*", rec.Metadata["pattern"])
+	assert.Equal(t, opencdc.RawData("users:1"), rec.Key)
+	assert.Equal(t, "user_message", string(rec.Payload.After.Bytes()))
+
+	assert.False(t, res.HasNext())
+}
+
+func TestNewCDCIterator_Sharded(t *testing.T) {
+	redisChannel := "subchannel"
+	conn := redigomock.NewConn()
+	conn.Command("SSUBSCRIBE", redisChannel).Expect([]interface{}{
+		[]byte("ssubscribe"),
+		[]byte(redisChannel),
+		int64(1),
+	})
+	conn.AddSubscriptionMessage([]interface{}{
+		[]byte("smessage"),
+		[]byte(redisChannel),
+		[]byte("value1"),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := NewPubSubIterator(ctx, conn, nil, config.Config{RedisKey: redisChannel, Mode: config.ModeShardPubSub}, nil)
+	assert.NoError(t, err)
+	assert.True(t, res.sharded)
+
+	assert.Eventually(t, res.HasNext, time.Second, 10*time.Millisecond)
+	rec, err := res.Next(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "smessage", rec.Metadata["type"])
+	assert.Equal(t, redisChannel, rec.Metadata["channel"])
+	assert.Equal(t, "value1", string(rec.Payload.After.Bytes()))
+	assert.NoError(t, res.Stop())
+}
+
+func TestNewCDCIterator_ShardedPattern(t *testing.T) {
+	_, err := NewPubSubIterator(context.Background(), redigomock.NewConn(), nil,
+		config.Config{RedisKey: "users:*", Mode: config.ModeShardPubSub}, nil)
+	assert.EqualError(t, err, "patterns are not supported in shardpubsub mode, got [users:*]")
+}
+
+func TestParseShardedReply(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply interface{}
+		err   error
+		want  interface{}
+	}{
+		{
+			name:  "message",
+			reply: []interface{}{[]byte("smessage"), []byte("channel"), []byte("data")},
+			want:  redis.Message{Channel: "channel", Data: []byte("data")},
+		}, {
+			name:  "subscription",
+			reply: []interface{}{[]byte("ssubscribe"), []byte("channel"), int64(1)},
+			want:  redis.Subscription{Kind: "ssubscribe", Channel: "channel", Count: 1},
+		}, {
+			name:  "pong",
+			reply: []interface{}{[]byte("pong"), []byte("data")},
+			want:  redis.Pong{Data: "data"},
+		}, {
+			name:  "unknown notification",
+			reply: []interface{}{[]byte("message"), []byte("channel"), []byte("data")},
+			want:  errors.New(`unknown sharded pubsub notification "message"`),
+		}, {
+			name: "receive error",
+			err:  errors.New("connection closed"),
+			want: errors.New("connection closed"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseShardedReply(tt.reply, tt.err))
+		})
+	}
+}
+
+func TestPubSubHandler_Decode(t *testing.T) {
+	want := opencdc.Record{
+		Operation: opencdc.OperationDelete,
+		Metadata:  opencdc.Metadata{"source": "billing"},
+		Key:       opencdc.RawData("user:1"),
+		Payload:   opencdc.Change{Before: opencdc.StructuredData{"name": "jane"}},
+	}
+	encoded, err := json.Marshal(want)
+	assert.NoError(t, err)
+
+	h := pubSubHandler{decode: true, instance: "instance-1"}
+	rec, ok, err := h.toRecord(context.Background(), redis.Message{Channel: "users", Data: encoded})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.NotEmpty(t, rec.Position)
+	want.Position = rec.Position
+	want.Metadata["instance"] = "instance-1"
+	want.Metadata["sequence"] = "1"
+	assert.Equal(t, want, rec)
+
+	_, _, err = h.toRecord(context.Background(), redis.Message{Channel: "users", Data: []byte("not a record")})
+	assert.ErrorContains(t, err, "error decoding message from channel(users)")
+}
+
+func TestPubSubHandler_Sequence(t *testing.T) {
+	ctx := context.Background()
+	h := &pubSubHandler{instance: "instance-1"}
+	positions := make([]opencdc.Position, 0, 3)
+	for idx, channel := range []string{"orders", "users", "orders"} {
+		rec, ok, err := h.toRecord(ctx, redis.Message{Channel: channel, Data: []byte("data")})
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, strconv.Itoa(idx+1), rec.Metadata["sequence"])
+		assert.Equal(t, "instance-1", rec.Metadata["instance"])
+
+		pos, ok, err := parsePubSubPosition(rec.Position)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, pubSubPosition{Instance: "instance-1", Channel: channel, Sequence: uint64(idx + 1)}, pos)
+		positions = append(positions, rec.Position)
+	}
+
+	assert.NoError(t, h.ack(ctx, positions[0]))
+	assert.NoError(t, h.ack(ctx, positions[2]))
+	assert.EqualError(t, h.ack(ctx, positions[1]), "ack for sequence 2 received out of order, sequence 3 was already acked")
+	// the positions of other instances and previous versions aren't verified
+	assert.NoError(t, h.ack(ctx, opencdc.Position(`{"instance":"instance-0","channel":"orders","sequence":1}`)))
+	assert.NoError(t, h.ack(ctx, opencdc.Position("orders_1652107432000000000")))
+}
+
+func TestNewPubSubHandler_Position(t *testing.T) {
+	// the sequence continues from the position the connector is opened with, with a new instance id
+	acked := opencdc.Position(`{"instance":"instance-1","channel":"orders","sequence":41}`)
+	h, err := newPubSubHandler(config.Config{}, acked)
+	assert.NoError(t, err)
+	assert.NotEqual(t, "instance-1", h.instance)
+	rec, _, err := h.toRecord(context.Background(), redis.Message{Channel: "orders", Data: []byte("data")})
+	assert.NoError(t, err)
+	assert.Equal(t, h.instance, rec.Metadata["instance"])
+	assert.Equal(t, "42", rec.Metadata["sequence"])
+	assert.NoError(t, h.ack(context.Background(), rec.Position))
+
+	// the records emitted without being acked before a restart don't share their positions with the records
+	// emitted after it, which continue from the same acked position
+	seen := make(map[string]bool)
+	for range 2 {
+		h, err := newPubSubHandler(config.Config{}, acked)
+		assert.NoError(t, err)
+		for range 3 {
+			rec, _, err := h.toRecord(context.Background(), redis.Message{Channel: "orders", Data: []byte("data")})
+			assert.NoError(t, err)
+			assert.False(t, seen[string(rec.Position)], "position %s repeated", rec.Position)
+			seen[string(rec.Position)] = true
+		}
+	}
+
+	// a new instance starts from the first sequence without position, or with the position of a previous version
+	for _, position := range []opencdc.Position{nil, opencdc.Position("orders_1652107432000000000")} {
+		h, err := newPubSubHandler(config.Config{}, position)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, h.instance)
+		assert.Zero(t, h.sequence)
+	}
+
+	_, err = newPubSubHandler(config.Config{}, opencdc.Position(`{"instance":`))
+	assert.ErrorContains(t, err, "invalid position")
+}
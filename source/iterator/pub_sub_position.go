@@ -0,0 +1,59 @@
+// Copyright © 2022 Meroxa, Inc. & Gophers Lab Technologies Pvt. Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+)
+
+// pubSubPosition is the position of a record created from a pubsub message. The sequence is incremented for each
+// message received by the connector instance, which is identified by a random id generated when it is opened.
+// The sequence continues from the position the connector is opened with, so the positions are ordered across restarts.
+type pubSubPosition struct {
+	Instance string `json:"instance"`
+	Channel  string `json:"channel"`
+	Sequence uint64 `json:"sequence"`
+}
+
+// toPosition encodes the pubSubPosition as JSON
+func (p pubSubPosition) toPosition() (opencdc.Position, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling the position: %w", err)
+	}
+	return b, nil
+}
+
+// parsePubSubPosition parses the JSON encoded pubSubPosition, returning false for the positions of previous
+// versions, formatted as <channel>_<unix_nano>, which don't have a sequence
+func parsePubSubPosition(position opencdc.Position) (pubSubPosition, bool, error) {
+	if len(position) == 0 || position[0] != '{' {
+		return pubSubPosition{}, false, nil
+	}
+	var pos pubSubPosition
+	if err := json.Unmarshal(position, &pos); err != nil {
+		return pubSubPosition{}, false, fmt.Errorf("invalid position(%s): %w", string(position), err)
+	}
+	return pos, true, nil
+}
+
+// newInstanceID returns the random id of the connector instance used in the pubsub positions
+func newInstanceID() string {
+	return rand.Text()
+}
@@ -0,0 +1,122 @@
+// Copyright © 2022 Meroxa, Inc. & Gophers Lab Technologies Pvt. Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/conduitio-labs/conduit-connector-redis/config"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	// maxReconnectBackoff is the upper bound of the delay between two reconnection attempts
+	maxReconnectBackoff = 30 * time.Second
+	// sentinelMaxAttempts is the number of attempts to follow a failover to the new master resolved with the
+	// sentinels, when reconnecting isn't enabled otherwise
+	sentinelMaxAttempts = 10
+)
+
+// Dialer creates a new connection to redis, it is used by the iterators to reconnect after a connection loss
+type Dialer func(ctx context.Context) (redis.Conn, error)
+
+// reconnector dials a new connection after a connection loss, waiting for an exponential backoff with jitter
+// between the attempts. Reconnecting is disabled when dial is nil, or when maxAttempts is zero unless the master
+// is resolved with the sentinels, in which case the failovers are always followed.
+type reconnector struct {
+	dial        Dialer
+	maxAttempts int
+	// maxTime bounds the total time spent reconnecting, it is unbounded when zero
+	maxTime time.Duration
+	backoff time.Duration
+	// sentinel is true when the master is resolved with the sentinels
+	sentinel bool
+}
+
+func newReconnector(dial Dialer, cfg config.Config) reconnector {
+	return reconnector{
+		dial:        dial,
+		maxAttempts: cfg.ReconnectMaxAttempts,
+		maxTime:     cfg.ReconnectMaxTime,
+		backoff:     cfg.ReconnectBackoff,
+		sentinel:    len(cfg.SentinelAddresses) > 0,
+	}
+}
+
+// canReconnect returns whether the error is a connection loss the iterator can recover from by reconnecting,
+// or a write rejected by a master turned into a replica after a failover
+func (r reconnector) canReconnect(err error) bool {
+	if r.dial == nil || (r.maxAttempts == 0 && !r.sentinel) {
+		return false
+	}
+	return config.IsFailover(err)
+}
+
+// reconnect dials a new connection until it succeeds or the max attempts or max time are exceeded,
+// it returns early when dying is closed
+func (r reconnector) reconnect(ctx context.Context, cause error, dying <-chan struct{}) (redis.Conn, error) {
+	maxAttempts := r.maxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = sentinelMaxAttempts
+	}
+	start := time.Now()
+	logger := sdk.Logger(ctx)
+	for attempt := 1; ; attempt++ {
+		delay := r.delay(attempt)
+		logger.Warn().
+			Err(cause).
+			Int("attempt", attempt).
+			Dur("backoff", delay).
+			Msg("connection to redis lost, reconnecting")
+
+		select {
+		case <-time.After(delay):
+		case <-dying:
+			return nil, cause
+		}
+
+		conn, err := r.dial(ctx)
+		if err == nil {
+			logger.Info().
+				Int("attempts", attempt).
+				Dur("downtime", time.Since(start)).
+				Msg("reconnected to redis")
+			return conn, nil
+		}
+		cause = err
+
+		if attempt >= maxAttempts || (r.maxTime > 0 && time.Since(start) >= r.maxTime) {
+			return nil, fmt.Errorf("failed to reconnect to redis after %d attempts: %w", attempt, err)
+		}
+	}
+}
+
+// delay returns the backoff before the attempt, doubled after each attempt up to maxReconnectBackoff,
+// with a random jitter of up to half of it so that the connectors don't reconnect at the same time
+func (r reconnector) delay(attempt int) time.Duration {
+	delay := r.backoff
+	for i := 1; i < attempt && delay < maxReconnectBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxReconnectBackoff)
+	if half := int64(delay / 2); half > 0 {
+		delay = time.Duration(half + rand.Int63n(half)) //nolint:gosec // the jitter doesn't need a secure random
+	}
+	return delay
+}
@@ -0,0 +1,307 @@
+// Copyright © 2022 Meroxa, Inc. & Gophers Lab Technologies Pvt. Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	"github.com/conduitio-labs/conduit-connector-redis/config"
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconnector_CanReconnect(t *testing.T) {
+	dial := func(context.Context) (redis.Conn, error) { return nil, nil }
+	r := reconnector{dial: dial, maxAttempts: 1}
+	assert.True(t, r.canReconnect(io.EOF))
+	assert.False(t, r.canReconnect(redis.Error("ERR wrong number of arguments")))
+	assert.True(t, r.canReconnect(fmt.Errorf("error reading: %w", redis.Error("READONLY You can't write against a read only replica."))))
+	assert.False(t, r.canReconnect(errors.New("invalid data")))
+
+	r.maxAttempts = 0
+	assert.False(t, r.canReconnect(io.EOF))
+
+	// the failovers are always followed with the sentinels
+	r.sentinel = true
+	assert.True(t, r.canReconnect(io.EOF))
+	assert.False(t, r.canReconnect(errors.New("invalid data")))
+}
+
+func TestReconnector_Delay(t *testing.T) {
+	r := reconnector{backoff: 100 * time.Millisecond}
+	for attempt, max := range map[int]time.Duration{1: 100 * time.Millisecond, 3: 400 * time.Millisecond, 20: maxReconnectBackoff} {
+		delay := r.delay(attempt)
+		assert.GreaterOrEqual(t, delay, max/2)
+		assert.Less(t, delay, max)
+	}
+}
+
+func TestReconnector_MaxAttempts(t *testing.T) {
+	dialErr := errors.New("connection refused")
+	attempts := 0
+	r := reconnector{
+		dial: func(context.Context) (redis.Conn, error) {
+			attempts++
+			return nil, dialErr
+		},
+		maxAttempts: 3,
+		backoff:     time.Millisecond,
+	}
+	_, err := r.reconnect(context.Background(), io.EOF, nil)
+	assert.ErrorIs(t, err, dialErr)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestReconnector_SentinelMaxAttempts(t *testing.T) {
+	attempts := 0
+	r := newReconnector(func(context.Context) (redis.Conn, error) {
+		attempts++
+		return nil, io.EOF
+	}, config.Config{SentinelAddresses: []string{"localhost:26379"}, ReconnectBackoff: time.Nanosecond})
+	_, err := r.reconnect(context.Background(), io.EOF, nil)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, sentinelMaxAttempts, attempts)
+}
+
+func TestStreamIterator_Reconnect(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	addr := mr.Addr()
+	dial := func(ctx context.Context) (redis.Conn, error) {
+		return redis.DialContext(ctx, "tcp", addr)
+	}
+	conn, err := dial(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = mr.XAdd("events", "1-0", []string{"key", "1-0"})
+	assert.NoError(t, err)
+
+	cfg := config.Config{
+		RedisKey:             "events",
+		PollingPeriod:        time.Millisecond,
+		ReconnectMaxAttempts: 100,
+		ReconnectBackoff:     time.Millisecond,
+	}
+	res, err := NewStreamIterator(context.Background(), conn, nil, dial, cfg, nil)
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, res.Stop())
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	rec, err := res.Next(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, opencdc.Position("1-0"), rec.Position)
+
+	// the connection is lost while the server is down, the stream is resumed after the last message read
+	mr.Close()
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, mr.Restart())
+	_, err = mr.XAdd("events", "2-0", []string{"key", "2-0"})
+	assert.NoError(t, err)
+
+	rec, err = res.Next(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, opencdc.Position("2-0"), rec.Position)
+}
+
+func TestPubSubIterator_Reconnect(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	addr := mr.Addr()
+	dial := func(ctx context.Context) (redis.Conn, error) {
+		return redis.DialContext(ctx, "tcp", addr)
+	}
+	conn, err := dial(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Config{RedisKey: "events", ReconnectMaxAttempts: 100, ReconnectBackoff: time.Millisecond}
+	res, err := NewPubSubIterator(context.Background(), conn, dial, cfg, nil)
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, res.Stop())
+	}()
+
+	mr.Close()
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, mr.Restart())
+	// the channel is subscribed to again on the new connection
+	assert.Eventually(t, func() bool {
+		return mr.PubSubNumSub("events")["events"] == 1
+	}, 5*time.Second, time.Millisecond)
+	mr.Publish("events", "after reconnect")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for {
+		rec, err := res.Next(ctx)
+		if errors.Is(err, sdk.ErrBackoffRetry) {
+			time.Sleep(time.Millisecond)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, opencdc.RawData("after reconnect"), rec.Payload.After)
+		return
+	}
+}
+
+func TestZSetIterator_Reconnect(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	addr := mr.Addr()
+	dial := func(ctx context.Context) (redis.Conn, error) {
+		return redis.DialContext(ctx, "tcp", addr)
+	}
+	conn, err := dial(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = mr.ZAdd("scores", 1, "a")
+	assert.NoError(t, err)
+
+	// the failovers are followed with the sentinels even though reconnecting isn't enabled
+	cfg := config.Config{
+		RedisKey:          "scores",
+		Mode:              config.ModeZSet,
+		PollingPeriod:     time.Millisecond,
+		SentinelAddresses: []string{"localhost:26379"},
+		ReconnectBackoff:  time.Millisecond,
+	}
+	res, err := NewZSetIterator(context.Background(), conn, dial, cfg, nil)
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, res.Stop())
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	rec, err := res.Next(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, opencdc.Position("1:a"), rec.Position)
+
+	// the sorted set is polled again on the new connection, after the last member read
+	mr.Close()
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, mr.Restart())
+	_, err = mr.ZAdd("scores", 2, "b")
+	assert.NoError(t, err)
+
+	rec, err = res.Next(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, opencdc.Position("2:b"), rec.Position)
+}
+
+func TestKeyspaceIterator_Reconnect(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	addr := mr.Addr()
+	configSets := make(chan string, 10)
+	// miniredis doesn't support CONFIG SET, the events enabled are recorded instead
+	hook := func(c *server.Peer, cmd string, args ...string) bool {
+		if !strings.EqualFold(cmd, "CONFIG") || len(args) != 3 {
+			return false
+		}
+		configSets <- args[2]
+		c.WriteOK()
+		return true
+	}
+	mr.Server().SetPreHook(hook)
+	dial := func(ctx context.Context) (redis.Conn, error) {
+		return redis.DialContext(ctx, "tcp", addr)
+	}
+	subConn, err := dial(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := dial(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Config{
+		RedisKey:             "user:1",
+		Mode:                 config.ModeKeyspace,
+		NotifyKeyspaceEvents: "KA",
+		ReconnectMaxAttempts: 100,
+		// the hook is set again before reconnecting
+		ReconnectBackoff: 100 * time.Millisecond,
+	}
+	res, err := NewKeyspaceIterator(context.Background(), subConn, conn, dial, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		assert.NoError(t, res.Stop())
+	}()
+	assert.Equal(t, "KA", <-configSets)
+
+	mr.Close()
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, mr.Restart())
+	mr.Server().SetPreHook(hook)
+	// the notifications are enabled again, as the new connection may be to a new master
+	select {
+	case events := <-configSets:
+		assert.Equal(t, "KA", events)
+	case <-time.After(5 * time.Second):
+		t.Fatal("keyspace notifications not enabled again")
+	}
+	assert.Eventually(t, func() bool {
+		return mr.PubSubNumSub("__keyspace@0__:user:1")["__keyspace@0__:user:1"] == 1
+	}, 5*time.Second, time.Millisecond)
+
+	// the value is fetched with the new value connection
+	mr.Set("user:1", "john")
+	mr.Publish("__keyspace@0__:user:1", "set")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for {
+		rec, err := res.Next(ctx)
+		if errors.Is(err, sdk.ErrBackoffRetry) {
+			time.Sleep(time.Millisecond)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, opencdc.RawData("john"), rec.Payload.After)
+		return
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright © 2022 Meroxa, Inc. & Gophers Lab Technologies Pvt. Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+)
+
+// decodeRecord decodes the JSON of a record written with the opencdc encoding. The position of the record
+// is replaced by the one of the message it was read from, the rest of the record is kept as it was written.
+func decodeRecord(b []byte, position opencdc.Position) (opencdc.Record, error) {
+	var rec opencdc.Record
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return opencdc.Record{}, fmt.Errorf("error decoding the opencdc record: %w", err)
+	}
+	rec.Position = position
+	if rec.Metadata == nil {
+		rec.Metadata = make(opencdc.Metadata)
+	}
+	// the missing data is decoded as empty structured data
+	rec.Key = nilIfEmpty(rec.Key)
+	rec.Payload.Before = nilIfEmpty(rec.Payload.Before)
+	rec.Payload.After = nilIfEmpty(rec.Payload.After)
+	return rec, nil
+}
+
+func nilIfEmpty(d opencdc.Data) opencdc.Data {
+	if sd, ok := d.(opencdc.StructuredData); ok && sd == nil {
+		return nil
+	}
+	return d
+}
@@ -0,0 +1,261 @@
+// Copyright © 2022 Meroxa, Inc. & Gophers Lab Technologies Pvt. Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/conduitio-labs/conduit-connector-redis/config"
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/gomodule/redigo/redis"
+)
+
+// streamGap is a range of messages trimmed from a stream after the last message read
+type streamGap struct {
+	Key string
+	// AfterID is the id of the last message read, the messages after it are missing
+	AfterID string
+	// BeforeID is the id of the first message left in the stream, empty when the stream is empty
+	BeforeID string
+	// LastDeletedID is the id of the last message deleted from the stream, empty when unknown
+	LastDeletedID string
+}
+
+func (g streamGap) Error() string {
+	return fmt.Sprintf("messages of key(%s) after id(%s) were trimmed, first available id is %q", g.Key, g.AfterID, g.BeforeID)
+}
+
+// streamInfo holds the fields of XINFO STREAM used to detect the gaps
+type streamInfo struct {
+	length          int
+	firstID         string
+	lastGeneratedID string
+	// maxDeletedID is only returned by redis 7.0 and later
+	maxDeletedID string
+}
+
+// checkGaps handles the gaps between the last message read from each key and the first message left in it,
+// according to the gap policy. The gap records are returned for the record policy, each gap is only handled once.
+func (i *StreamIterator) checkGaps(ctx context.Context) ([]opencdc.Record, error) {
+	if i.gapPolicy == "" {
+		return nil, nil
+	}
+
+	var records []opencdc.Record
+	for _, key := range i.keys {
+		gap, ok, err := i.findGap(key)
+		if err != nil {
+			return nil, err
+		}
+		if !ok || i.reportedGaps[key] == gap.AfterID {
+			continue
+		}
+		i.reportedGaps[key] = gap.AfterID
+
+		switch i.gapPolicy {
+		case config.GapPolicyFail:
+			return nil, gap
+		case config.GapPolicyWarn:
+			sdk.Logger(ctx).Warn().
+				Str("key", key).
+				Str("after_id", gap.AfterID).
+				Str("before_id", gap.BeforeID).
+				Str("last_deleted_id", gap.LastDeletedID).
+				Msg("messages were trimmed from the stream before being read, continuing with the first available message")
+		case config.GapPolicyRecord:
+			rec, err := i.gapRecord(gap)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, rec)
+		}
+	}
+	return records, nil
+}
+
+// findGap returns the gap between the last message read from the key and the first message left in it, if any
+func (i *StreamIterator) findGap(key string) (streamGap, bool, error) {
+	lastID := i.lastIDs[key]
+	if lastID == config.StreamIDEarliest {
+		// reading from the beginning, the first available message is the expected one
+		return streamGap{}, false, nil
+	}
+
+	info, ok, err := i.streamInfo(key)
+	if err != nil || !ok {
+		return streamGap{}, false, err
+	}
+	gap := streamGap{Key: key, AfterID: lastID, BeforeID: info.firstID, LastDeletedID: info.maxDeletedID}
+
+	if info.maxDeletedID != "" {
+		// nothing was deleted after the last message read
+		if cmp, err := compareStreamIDs(info.maxDeletedID, lastID); err != nil || cmp <= 0 {
+			return streamGap{}, false, err
+		}
+	}
+	if info.firstID != "" {
+		// the messages after the last one read are still available, or the first one left is the next possible id,
+		// which is the case when the stream was trimmed right at the last message read
+		nextID, err := nextStreamID(lastID)
+		if err != nil {
+			return streamGap{}, false, err
+		}
+		if cmp, err := compareStreamIDs(info.firstID, nextID); err != nil || cmp <= 0 {
+			return streamGap{}, false, err
+		}
+		return gap, true, nil
+	}
+
+	// the stream is empty, the messages added after the last one read were all deleted
+	if cmp, err := compareStreamIDs(info.lastGeneratedID, lastID); err != nil || cmp <= 0 {
+		return streamGap{}, false, err
+	}
+	if gap.LastDeletedID == "" {
+		gap.LastDeletedID = info.lastGeneratedID
+	}
+	return gap, true, nil
+}
+
+// streamInfo fetches the info of the stream with XINFO STREAM, returning false when the key doesn't exist
+func (i *StreamIterator) streamInfo(key string) (streamInfo, bool, error) {
+	resp, err := redis.Values(i.do("XINFO", "STREAM", key))
+	if err != nil {
+		if strings.Contains(err.Error(), "no such key") {
+			return streamInfo{}, false, nil
+		}
+		return streamInfo{}, false, fmt.Errorf("error fetching info of stream(%s): %w", key, err)
+	}
+
+	var info streamInfo
+	for idx := 0; idx+1 < len(resp); idx += 2 {
+		name, err := redis.String(resp[idx], nil)
+		if err != nil {
+			return streamInfo{}, false, fmt.Errorf("invalid XINFO STREAM field: %w", err)
+		}
+		value := resp[idx+1]
+		switch name {
+		case "length":
+			info.length, err = redis.Int(value, nil)
+		case "last-generated-id":
+			info.lastGeneratedID, err = redis.String(value, nil)
+		case "max-deleted-entry-id":
+			info.maxDeletedID, err = redis.String(value, nil)
+		case "recorded-first-entry-id":
+			// it is 0-0 when the stream is empty
+			if id, _ := redis.String(value, nil); id != config.StreamIDEarliest {
+				info.firstID = id
+			}
+		case "first-entry":
+			if value == nil || info.firstID != "" {
+				continue
+			}
+			var id []byte
+			id, _, err = parsePositionData(value)
+			info.firstID = string(id)
+		}
+		if err != nil {
+			return streamInfo{}, false, fmt.Errorf("invalid XINFO STREAM field(%s): %w", name, err)
+		}
+	}
+
+	if info.length > 0 && info.firstID == "" {
+		// the first entry isn't returned by all the servers, fetch it separately
+		entries, err := redis.Values(i.do("XRANGE", key, "-", "+", "COUNT", 1))
+		if err != nil {
+			return streamInfo{}, false, fmt.Errorf("error fetching first message of key(%s): %w", key, err)
+		}
+		if len(entries) > 0 {
+			id, _, err := parsePositionData(entries[0])
+			if err != nil {
+				return streamInfo{}, false, fmt.Errorf("error parsing first message of key(%s): %w", key, err)
+			}
+			info.firstID = string(id)
+		}
+	}
+	return info, true, nil
+}
+
+// gapRecord creates the synthetic record reporting the gap. Its position is the one of the last message read
+// marked as a gap, so that it is distinct from the position of that message, and that the gap isn't reported
+// again when the connector is restarted from it.
+func (i *StreamIterator) gapRecord(gap streamGap) (opencdc.Record, error) {
+	position, err := streamPosition{Key: gap.Key, ID: gap.AfterID, LastIDs: i.lastIDs, Gap: true}.toPosition()
+	if err != nil {
+		return opencdc.Record{}, err
+	}
+	metadata := opencdc.Metadata{
+		"type":              "gap",
+		"key":               gap.Key,
+		"gap.afterId":       gap.AfterID,
+		"gap.beforeId":      gap.BeforeID,
+		"gap.lastDeletedId": gap.LastDeletedID,
+	}
+	metadata.SetCreatedAt(time.Now())
+
+	return sdk.Util.Source.NewRecordCreate(position, metadata, opencdc.RawData(gap.Key), nil), nil
+}
+
+// compareStreamIDs compares the stream ids, returning -1, 0 or 1 when a is lower, equal or greater than b
+func compareStreamIDs(a, b string) (int, error) {
+	aMs, aSeq, err := parseStreamID(a)
+	if err != nil {
+		return 0, err
+	}
+	bMs, bSeq, err := parseStreamID(b)
+	if err != nil {
+		return 0, err
+	}
+
+	if c := cmp.Compare(aMs, bMs); c != 0 {
+		return c, nil
+	}
+	return cmp.Compare(aSeq, bSeq), nil
+}
+
+// nextStreamID returns the lowest id greater than the stream id
+func nextStreamID(id string) (string, error) {
+	ms, seq, err := parseStreamID(id)
+	if err != nil {
+		return "", err
+	}
+	if seq == math.MaxUint64 {
+		return fmt.Sprintf("%d-0", ms+1), nil
+	}
+	return fmt.Sprintf("%d-%d", ms, seq+1), nil
+}
+
+// parseStreamID parses the <ms>-<seq> stream id, the sequence number is optional
+func parseStreamID(id string) (uint64, uint64, error) {
+	rawMs, rawSeq, hasSeq := strings.Cut(id, "-")
+	ms, err := strconv.ParseUint(rawMs, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid stream id(%s): %w", id, err)
+	}
+	if !hasSeq {
+		return ms, 0, nil
+	}
+	seq, err := strconv.ParseUint(rawSeq, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid stream id(%s): %w", id, err)
+	}
+	return ms, seq, nil
+}
@@ -16,13 +16,15 @@ package iterator
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/conduitio-labs/conduit-connector-redis/cluster"
+	"github.com/conduitio-labs/conduit-connector-redis/config"
 	"github.com/conduitio/conduit-commons/opencdc"
 	sdk "github.com/conduitio/conduit-connector-sdk"
 	"github.com/gomodule/redigo/redis"
@@ -32,13 +34,71 @@ import (
 const (
 	keyTypeNone   = "none"
 	keyTypeStream = "stream"
+
+	// groupPendingID is used with XREADGROUP to read the messages already delivered to this consumer
+	// but not acknowledged yet, groupNewID is used to read the messages never delivered to any consumer
+	groupPendingID = "0"
+	groupNewID     = ">"
+)
+
+var (
+	errClientClosed = errors.New("redis client is closed")
+
+	// ErrEndReached is returned by Next once all the messages up to the end ids were read
+	ErrEndReached = errors.New("end of stream reached")
+
+	// closedTimeChan is a closed channel, receiving from it never blocks
+	closedTimeChan = func() <-chan time.Time {
+		c := make(chan time.Time)
+		close(c)
+		return c
+	}()
 )
 
 type StreamIterator struct {
-	key             string
-	client          redis.Conn
-	tomb            *tomb.Tomb
-	lastID          string
+	keys []string
+	// keyGroups holds the keys read with a single command, which are grouped by slot in a cluster, as the commands
+	// can't span keys of multiple slots, or form a single group otherwise
+	keyGroups [][]string
+	// composite is true when reading multiple keys, in which case the position of the records is a streamPosition
+	composite     bool
+	group         string
+	consumer      string
+	claimMinIdle  time.Duration
+	maxDeliveries int
+	deadLetterKey string
+	claimCursors  map[string]string
+	client        redis.Conn
+	// blockClient is used to read the streams with XREAD BLOCK when blockTimeout is set, it is dedicated to the
+	// iterator go routine and closed by Stop to interrupt a blocked read
+	blockClient  redis.Conn
+	blockTimeout time.Duration
+	mux          *sync.Mutex
+	tomb         *tomb.Tomb
+	// lastIDs holds the id of the last message read from each key
+	lastIDs map[string]string
+	// pendingIDs holds the id of the last pending message read again from each key, when reading as part of a
+	// consumer group, the key is removed once all the messages pending for the consumer were delivered again
+	pendingIDs map[string]string
+	// endIDs holds the id each key is read up to with XRANGE, the keys are read forever when nil,
+	// ended holds the keys read up to their end id
+	endIDs map[string]string
+	ended  map[string]bool
+	// gapPolicy is the behavior when messages after the last one read were trimmed, the gaps aren't checked when empty,
+	// reportedGaps holds the last id read when the gap of each key was handled
+	gapPolicy    string
+	reportedGaps map[string]string
+	// payloadFormat is the format of the record payloads, fieldTypes the types their fields are coerced to
+	payloadFormat string
+	fieldTypes    map[string]string
+	// keyField, operationField and metadataPrefix map the message fields to the record key, operation and metadata
+	keyField       string
+	operationField string
+	metadataPrefix string
+	// encoding is the encoding of the records written to the stream, the whole records are decoded when set
+	encoding string
+	// reconnector re-establishes the connections after a connection loss, the keys are resumed from lastIDs
+	reconnector     reconnector
 	recordsPerCall  int
 	pollingInterval time.Duration
 	ticker          *time.Ticker
@@ -47,37 +107,101 @@ type StreamIterator struct {
 }
 
 // NewStreamIterator creates a new instance of redis stream iterator and starts polling redis stream for new changes
-// using the last record id of last successful row read, in a separate go routine
+// using the last record id of last successful row read, in a separate go routine.
+// blockClient is only used when cfg.BlockTimeout is set and can be nil otherwise, the same way as dial,
+// which is only used to reconnect after a connection loss when enabled in cfg.
 func NewStreamIterator(ctx context.Context,
-	client redis.Conn,
-	key string,
-	pollingInterval time.Duration,
+	client, blockClient redis.Conn,
+	dial Dialer,
+	cfg config.Config,
 	position opencdc.Position,
 ) (*StreamIterator, error) {
-	keyType, err := redis.String(client.Do("TYPE", key))
+	if cfg.BlockTimeout > 0 && blockClient == nil {
+		return nil, errors.New("a dedicated client is required to read the stream with a block timeout")
+	}
+
+	keys, composite, err := resolveKeys(client, cfg.Keys())
 	if err != nil {
-		return nil, fmt.Errorf("error fetching type of key(%s): %w", key, err)
+		return nil, err
 	}
-	if keyType != keyTypeNone && keyType != keyTypeStream {
-		return nil, fmt.Errorf("invalid key type: %s, expected none or stream", keyType)
+	keyGroups := groupKeys(keys, len(cfg.ClusterAddresses) > 0)
+	if cfg.BlockTimeout > 0 && len(keyGroups) > 1 {
+		return nil, errors.New("reading the streams with a block timeout in a cluster requires the keys to be in the same slot, use a hash tag")
 	}
 
-	tmbWithCtx, _ := tomb.WithContext(ctx)
-	ticker := time.NewTicker(pollingInterval)
+	pos, err := parseStreamPosition(position, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	lastIDs := make(map[string]string, len(keys))
+	for _, key := range keys {
+		lastID := pos.LastIDs[key]
+		if lastID == "" {
+			// if position is empty, start from the configured id
+			if lastID, err = startID(client, key, cfg.StartFrom); err != nil {
+				return nil, err
+			}
+		}
+		lastIDs[key] = lastID
+	}
+
+	var pendingIDs map[string]string
+	if cfg.ConsumerGroup != "" {
+		pendingIDs = make(map[string]string, len(keys))
+		for _, key := range keys {
+			if err := createGroup(client, key, cfg.ConsumerGroup, lastIDs[key]); err != nil {
+				return nil, err
+			}
+			// first re-read the messages delivered to this consumer in a previous run, which were never acked
+			pendingIDs[key] = groupPendingID
+		}
+	}
 
-	lastID := string(position)
-	if lastID == "" {
-		// if position is empty, start from 0 record
-		lastID = "0-0"
+	var endIDs map[string]string
+	if cfg.EndAt != "" {
+		endIDs = make(map[string]string, len(keys))
+		for _, key := range keys {
+			// the latest id is resolved the same way as for startFrom, up to the last message at the time of opening
+			if endIDs[key], err = startID(client, key, cfg.EndAt); err != nil {
+				return nil, err
+			}
+		}
 	}
 
+	tmbWithCtx, _ := tomb.WithContext(ctx)
+	ticker := time.NewTicker(cfg.PollingPeriod)
+
 	cdc := &StreamIterator{
-		key:             key,
+		keys:            keys,
+		keyGroups:       keyGroups,
+		composite:       composite,
+		group:           cfg.ConsumerGroup,
+		consumer:        cfg.ConsumerName,
+		claimMinIdle:    cfg.ClaimMinIdleTime,
+		maxDeliveries:   cfg.MaxDeliveries,
+		deadLetterKey:   cfg.DeadLetterKey,
+		claimCursors:    make(map[string]string, len(keys)),
 		client:          client,
+		blockClient:     blockClient,
+		blockTimeout:    cfg.BlockTimeout,
+		mux:             &sync.Mutex{},
 		tomb:            tmbWithCtx,
 		recordsPerCall:  1000, // move this to config?
-		lastID:          lastID,
-		pollingInterval: pollingInterval,
+		lastIDs:         lastIDs,
+		pendingIDs:      pendingIDs,
+		endIDs:          endIDs,
+		ended:           make(map[string]bool, len(keys)),
+		gapPolicy:       cfg.GapPolicy,
+		reportedGaps:    make(map[string]string, len(keys)),
+		payloadFormat:   cfg.PayloadFormat,
+		fieldTypes:      cfg.FieldTypes,
+		keyField:        cfg.KeyField,
+		operationField:  cfg.OperationField,
+		metadataPrefix:  cfg.MetadataPrefix,
+		encoding:        cfg.Encoding,
+		reconnector:     newReconnector(dial, cfg),
+		pollingInterval: cfg.PollingPeriod,
 		ticker:          ticker,
 		// keeping the buffer length as 1, so that we are not blocked by one cache
 		// we have other batch of records ready once first batch is read
@@ -85,6 +209,17 @@ func NewStreamIterator(ctx context.Context,
 		buffer: make(chan opencdc.Record, 1),
 	}
 
+	if pos.Gap {
+		// restarting from a gap record, the gap was already reported
+		cdc.reportedGaps[pos.Key] = pos.ID
+	}
+	if cdc.gapPolicy == config.GapPolicyFail {
+		// fail right away instead of on the first poll
+		if _, err := cdc.checkGaps(ctx); err != nil {
+			return nil, err
+		}
+	}
+
 	cdc.tomb.Go(cdc.startIterator(ctx))
 	cdc.tomb.Go(cdc.flush)
 
@@ -100,19 +235,70 @@ func (i *StreamIterator) HasNext() bool {
 // and there was an error leading to tomb dying or context was cancelled
 func (i *StreamIterator) Next(ctx context.Context) (opencdc.Record, error) {
 	select {
-	case rec := <-i.buffer:
+	case rec, ok := <-i.buffer:
+		if !ok {
+			return opencdc.Record{}, i.stopErr()
+		}
 		return rec, nil
 	case <-i.tomb.Dying():
-		return opencdc.Record{}, i.tomb.Err()
+		// the buffer is closed with the last records once the end ids are reached, return them first
+		select {
+		case rec, ok := <-i.buffer:
+			if ok {
+				return rec, nil
+			}
+		default:
+		}
+		return opencdc.Record{}, i.stopErr()
 	case <-ctx.Done():
 		return opencdc.Record{}, ctx.Err()
 	}
 }
 
+// stopErr returns the error the go routines stopped with, which is ErrEndReached when they returned
+// after reading all the messages up to the end ids
+func (i *StreamIterator) stopErr() error {
+	if err := i.tomb.Err(); err != nil && !errors.Is(err, tomb.ErrStillAlive) {
+		return err
+	}
+	return ErrEndReached
+}
+
+// Ack acknowledges the message with the id in position using XACK, when reading as part of a consumer group.
+// Without a consumer group redis doesn't track the delivered messages, so there is nothing to acknowledge.
+func (i *StreamIterator) Ack(_ context.Context, position opencdc.Position) error {
+	if i.group == "" {
+		return nil
+	}
+	pos, err := parseStreamPosition(position, i.keys)
+	if err != nil {
+		return err
+	}
+	if _, err := i.do("XACK", pos.Key, i.group, pos.ID); err != nil {
+		return fmt.Errorf("error acknowledging message(%s) in group(%s): %w", pos.ID, i.group, err)
+	}
+	return nil
+}
+
 // Stop stops the go routines
 func (i *StreamIterator) Stop() error {
 	i.ticker.Stop()
 	i.tomb.Kill(errors.New("iterator stopped"))
+
+	// the blocking client is replaced by the iterator go routine when reconnecting
+	i.mux.Lock()
+	blockClient := i.blockClient
+	i.mux.Unlock()
+	if blockClient != nil {
+		// closing the connection interrupts the blocked read right away, instead of waiting for the block timeout
+		if err := blockClient.Close(); err != nil {
+			return fmt.Errorf("error closing the blocking redis client: %w", err)
+		}
+	}
+
+	// the client is shared with the iterator go routine and Ack, wait for any in-flight command to finish
+	i.mux.Lock()
+	defer i.mux.Unlock()
 	if err := i.client.Close(); err != nil {
 		return fmt.Errorf("error closing the redis client: %w", err)
 	}
@@ -120,99 +306,589 @@ func (i *StreamIterator) Stop() error {
 	return nil
 }
 
-// startIterator is the go routine function used to poll the redis stream for new changes at regular intervals
-func (i *StreamIterator) startIterator(_ context.Context) func() error {
+// reconnect replaces the connections after a connection loss, the keys are resumed from the last message read.
+// Both connections are replaced, as they are lost at the same time when the server is unreachable.
+func (i *StreamIterator) reconnect(ctx context.Context, cause error) error {
+	client, err := i.reconnector.reconnect(ctx, cause, i.tomb.Dying())
+	if err != nil {
+		return err
+	}
+	var blockClient redis.Conn
+	if i.blockTimeout > 0 {
+		if blockClient, err = i.reconnector.dial(ctx); err != nil {
+			_ = client.Close()
+			return fmt.Errorf("failed to reconnect the blocking redis client: %w", err)
+		}
+	}
+
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	if i.client == nil {
+		// stopped while reconnecting
+		_ = client.Close()
+		if blockClient != nil {
+			_ = blockClient.Close()
+		}
+		return errClientClosed
+	}
+	// the connections are broken already, the errors closing them don't matter
+	_ = i.client.Close()
+	i.client = client
+	if blockClient != nil {
+		_ = i.blockClient.Close()
+		i.blockClient = blockClient
+	}
+	sdk.Logger(ctx).Info().Interface("last_ids", i.lastIDs).Msg("resuming the streams after reconnecting")
+	return nil
+}
+
+// do runs the command on the redis client, serializing the access to it
+func (i *StreamIterator) do(cmd string, args ...interface{}) (interface{}, error) {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	if i.client == nil {
+		return nil, errClientClosed
+	}
+	return i.client.Do(cmd, args...)
+}
+
+// read fetches the next batch of messages from the streams, using XREADGROUP when reading as part of a consumer group.
+// The key groups are read one after the other, it returns redis.ErrNil when none of them has new messages.
+func (i *StreamIterator) read() ([]interface{}, error) {
+	if i.endIDs != nil {
+		return i.readRange()
+	}
+
+	resp := make([]interface{}, 0, len(i.keys))
+	nilReplies := 0
+	for _, keys := range i.keyGroups {
+		groupResp, err := i.readKeys(keys)
+		if errors.Is(err, redis.ErrNil) {
+			nilReplies++
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, groupResp...)
+	}
+	if nilReplies == len(i.keyGroups) {
+		return nil, redis.ErrNil
+	}
+	return resp, nil
+}
+
+// readKeys fetches the next batch of messages from the keys with a single command
+func (i *StreamIterator) readKeys(keys []string) ([]interface{}, error) {
+	args := make([]interface{}, 0, 2*len(keys))
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	for _, key := range keys {
+		args = append(args, i.readID(key))
+	}
+
+	cmd := "XREAD"
+	opts := []interface{}{"COUNT", i.recordsPerCall}
+	if i.group != "" {
+		cmd = "XREADGROUP"
+		opts = []interface{}{"GROUP", i.group, i.consumer, "COUNT", i.recordsPerCall}
+	}
+
+	if i.blockTimeout > 0 {
+		// the blocking client is only used by the iterator go routine, it doesn't need to be serialized
+		opts = append(opts, "BLOCK", i.blockTimeout.Milliseconds(), "STREAMS")
+		return redis.Values(i.blockClient.Do(cmd, append(opts, args...)...))
+	}
+	opts = append(opts, "STREAMS")
+	return redis.Values(i.do(cmd, append(opts, args...)...))
+}
+
+// readRange fetches the next batch of messages up to the end ids using XRANGE, in the format of the XREAD response.
+// It returns ErrEndReached once all the keys were read up to their end id.
+func (i *StreamIterator) readRange() ([]interface{}, error) {
+	resp := make([]interface{}, 0, len(i.keys))
+	for _, key := range i.keys {
+		if i.ended[key] {
+			continue
+		}
+		entries, err := redis.Values(i.do("XRANGE", key, "("+i.lastIDs[key], i.endIDs[key], "COUNT", i.recordsPerCall))
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) < i.recordsPerCall {
+			i.ended[key] = true
+		}
+		if len(entries) > 0 {
+			resp = append(resp, []interface{}{[]byte(key), entries})
+		}
+	}
+	if len(resp) == 0 && len(i.ended) == len(i.keys) {
+		return nil, ErrEndReached
+	}
+	return resp, nil
+}
+
+// readID returns the id to read the key from, which is the id of the last message read from the key
+// or, in a consumer group, either the last pending message read again or the new messages of the group
+func (i *StreamIterator) readID(key string) string {
+	if i.group == "" {
+		return i.lastIDs[key]
+	}
+	if id, ok := i.pendingIDs[key]; ok {
+		return id
+	}
+	return groupNewID
+}
+
+// startIterator is the go routine function used to poll the redis stream for new changes at regular intervals,
+// the connections are re-established after a connection loss when enabled
+func (i *StreamIterator) startIterator(ctx context.Context) func() error {
 	return func() error {
-		defer close(i.caches)
-		for {
-			select {
-			case <-i.tomb.Dying():
-				return i.tomb.Err()
-			case <-i.ticker.C:
-				resp, err := redis.Values(i.client.Do("XREAD", "COUNT", i.recordsPerCall, "STREAMS", i.key, i.lastID))
-				if err != nil {
-					if err == redis.ErrNil {
-						continue
-					}
-					return fmt.Errorf("error reading data from stream: %w", err)
-				}
-				records, err := toRecords(resp)
-				if err != nil {
-					return fmt.Errorf("error converting stream data to records: %w", err)
-				}
+		return pollRecords(i.tomb, i.wait, func() ([]opencdc.Record, error) {
+			records, err := i.poll(ctx)
+			switch {
+			case errors.Is(err, ErrEndReached):
+				sdk.Logger(ctx).Info().Strs("keys", i.keys).Msg("all messages up to the end ids were read")
+				return nil, err
+			case err == nil:
+				return records, nil
+			case !i.tomb.Alive():
+				// the blocked read was interrupted by Stop
+				return nil, i.tomb.Err()
+			case !i.reconnector.canReconnect(err):
+				return nil, err
+			}
+			return nil, i.reconnect(ctx, err)
+		}, i.caches)
+	}
+}
 
-				// ensure we don't fetch and keep a lot of records in memory
-				// block till flush reads current array of records
-				select {
-				case i.caches <- records:
-					i.lastID = string(records[len(records)-1].Position)
+// wait returns the channel the iterator go routine waits on before polling, which is the ticker channel
+// unless reading with a block timeout, in which case redis waits for new messages and the poll starts right away
+func (i *StreamIterator) wait() <-chan time.Time {
+	if i.blockTimeout > 0 {
+		return closedTimeChan
+	}
+	return i.ticker.C
+}
 
-				case <-i.tomb.Dying():
-					return i.tomb.Err()
-				}
+// poll returns the next batch of records, the stale pending messages claimed from other consumers
+// are returned before reading the new messages of the stream
+func (i *StreamIterator) poll(ctx context.Context) ([]opencdc.Record, error) {
+	// the gap records are returned before the messages following the gap
+	gapRecords, err := i.checkGaps(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(gapRecords) > 0 {
+		return gapRecords, nil
+	}
+
+	// claiming is skipped while re-reading the own pending messages, claimed messages become pending for
+	// this consumer and would be delivered twice otherwise
+	if i.claimMinIdle > 0 && len(i.pendingIDs) == 0 {
+		records, err := i.claim(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) > 0 {
+			return records, nil
+		}
+	}
+
+	resp, err := i.read()
+	if err != nil {
+		if err == redis.ErrNil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading data from stream: %w", err)
+	}
+	records, keys, err := i.toRecords(resp)
+	if err != nil {
+		return nil, fmt.Errorf("error converting stream data to records: %w", err)
+	}
+
+	for idx := range records {
+		key := keys[idx]
+		id := string(records[idx].Position)
+
+		i.lastIDs[key] = id
+		if _, ok := i.pendingIDs[key]; ok {
+			i.pendingIDs[key] = id
+		}
+		if records[idx].Position, err = i.position(key, id); err != nil {
+			return nil, err
+		}
+	}
+	// reading the pending messages of the consumer returns the key with an empty list
+	// once all of them were delivered again, continue with the new messages of that key
+	read := make(map[string]bool, len(i.keys))
+	for _, iKey := range resp {
+		if key, idList, err := parseKeyData(iKey); err == nil && len(idList) > 0 {
+			read[string(key)] = true
+		}
+	}
+	for key := range i.pendingIDs {
+		if !read[key] {
+			delete(i.pendingIDs, key)
+		}
+	}
+	return records, nil
+}
+
+// position returns the position of the message with the id read from the key, the plain message id is used
+// when reading a single key, to keep the positions compatible with the ones of previous versions
+func (i *StreamIterator) position(key, id string) (opencdc.Position, error) {
+	if !i.composite {
+		return opencdc.Position(id), nil
+	}
+	return streamPosition{Key: key, ID: id, LastIDs: i.lastIDs}.toPosition()
+}
+
+// claim transfers the messages pending in the group for at least claimMinIdle to this consumer using XAUTOCLAIM
+// and returns them as records. The messages already delivered maxDeliveries times are moved to the dead letter stream.
+func (i *StreamIterator) claim(ctx context.Context) ([]opencdc.Record, error) {
+	records := make([]opencdc.Record, 0)
+	for _, key := range i.keys {
+		claimed, err := i.claimKey(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, claimed...)
+	}
+	return records, nil
+}
+
+// claimKey claims the stale pending messages of a single key, continuing from the cursor returned by the last call
+func (i *StreamIterator) claimKey(ctx context.Context, key string) ([]opencdc.Record, error) {
+	minIdle := i.claimMinIdle.Milliseconds()
+	if i.maxDeliveries > 0 {
+		if err := i.deadLetter(ctx, key, minIdle); err != nil {
+			return nil, err
+		}
+	}
+
+	cursor, ok := i.claimCursors[key]
+	if !ok {
+		cursor = "0-0"
+	}
+	resp, err := redis.Values(i.do("XAUTOCLAIM", key, i.group, i.consumer, minIdle, cursor, "COUNT", i.recordsPerCall))
+	if err != nil {
+		return nil, fmt.Errorf("error claiming pending messages: %w", err)
+	}
+	if len(resp) < 2 {
+		return nil, fmt.Errorf("invalid XAUTOCLAIM response, expected at least 2 elements, got %d", len(resp))
+	}
+	cursor, err = redis.String(resp[0], nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid XAUTOCLAIM cursor: %w", err)
+	}
+	entries, ok := resp[1].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("resp[1]: invalid data type encountered, expected:%T, got:%T", entries, resp[1])
+	}
+	i.claimCursors[key] = cursor
+
+	// redis < 7 returns the messages deleted from the stream while pending without any fields,
+	// they are acknowledged by toRecords
+	records, _, err := i.toRecords([]interface{}{[]interface{}{[]byte(key), entries}})
+	if err != nil {
+		return nil, fmt.Errorf("error converting claimed data to records: %w", err)
+	}
+	for idx := range records {
+		// claimed messages were read before, they don't move the last id read from the key
+		if records[idx].Position, err = i.position(key, string(records[idx].Position)); err != nil {
+			return nil, err
+		}
+	}
+	if len(records) > 0 {
+		sdk.Logger(ctx).Info().
+			Int("count", len(records)).
+			Str("key", key).
+			Str("group", i.group).
+			Msg("claimed stale pending messages")
+	}
+	return records, nil
+}
+
+// deadLetter moves the messages pending for at least minIdle milliseconds, which were already delivered
+// maxDeliveries times, to the dead letter stream and acknowledges them in the group
+func (i *StreamIterator) deadLetter(ctx context.Context, key string, minIdle int64) error {
+	pending, err := redis.Values(i.do("XPENDING", key, i.group, "IDLE", minIdle, "-", "+", i.recordsPerCall))
+	if err != nil {
+		return fmt.Errorf("error fetching pending messages: %w", err)
+	}
+
+	for _, p := range pending {
+		// each pending message is returned as [id, consumer, idle time, delivery count]
+		info, err := redis.Values(p, nil)
+		if err != nil || len(info) < 4 {
+			return fmt.Errorf("invalid XPENDING entry received: %v", p)
+		}
+		id, err := redis.String(info[0], nil)
+		if err != nil {
+			return fmt.Errorf("invalid XPENDING id: %w", err)
+		}
+		deliveries, err := redis.Int(info[3], nil)
+		if err != nil {
+			return fmt.Errorf("invalid XPENDING delivery count: %w", err)
+		}
+		if deliveries < i.maxDeliveries {
+			continue
+		}
+
+		entries, err := redis.Values(i.do("XRANGE", key, id, id))
+		if err != nil {
+			return fmt.Errorf("error fetching message(%s): %w", id, err)
+		}
+		// the message might have been deleted from the stream, in which case it is only acknowledged
+		if len(entries) > 0 {
+			_, fieldList, err := parsePositionData(entries[0])
+			if err != nil {
+				return err
+			}
+			args := []interface{}{i.deadLetterKey, "*"}
+			args = append(args, fieldList...)
+			if _, err := i.do("XADD", args...); err != nil {
+				return fmt.Errorf("error adding message(%s) to dead letter key(%s): %w", id, i.deadLetterKey, err)
 			}
 		}
+		if _, err := i.do("XACK", key, i.group, id); err != nil {
+			return fmt.Errorf("error acknowledging message(%s) in group(%s): %w", id, i.group, err)
+		}
+
+		sdk.Logger(ctx).Warn().
+			Str("key", key).
+			Str("id", id).
+			Int("deliveries", deliveries).
+			Str("dead_letter_key", i.deadLetterKey).
+			Msg("message exceeded max deliveries, moved to dead letter key")
 	}
+	return nil
 }
 
-// flush is the go routine, responsible for getting the array of records in caches channel
-// and pushing them into read buffer to be returned by Next function
+// flush is the go routine pushing the records polled by startIterator into the buffer read by Next
 func (i *StreamIterator) flush() error {
-	defer close(i.buffer)
-	for {
-		select {
-		case <-i.tomb.Dying():
-			return i.tomb.Err()
-		case cache := <-i.caches:
-			for _, record := range cache {
-				select {
-				case <-i.tomb.Dying():
-					return i.tomb.Err()
-				case i.buffer <- record:
-				}
+	return flushRecords(i.tomb, i.caches, i.buffer)
+}
+
+// resolveKeys validates the configured keys and resolves the patterns among them to the matching stream keys.
+// It also returns whether the records need a composite position, which is the case for multiple keys or patterns.
+func resolveKeys(client redis.Conn, patterns []string) ([]string, bool, error) {
+	composite := len(patterns) > 1
+	keys := make([]string, 0, len(patterns))
+	seen := make(map[string]bool, len(patterns))
+	for _, pattern := range patterns {
+		matched := []string{pattern}
+		if config.IsPattern(pattern) {
+			composite = true
+			var err error
+			if matched, err = scanStreamKeys(client, pattern); err != nil {
+				return nil, false, err
 			}
+		} else {
+			keyType, err := redis.String(client.Do("TYPE", pattern))
+			if err != nil {
+				return nil, false, fmt.Errorf("error fetching type of key(%s): %w", pattern, err)
+			}
+			if keyType != keyTypeNone && keyType != keyTypeStream {
+				return nil, false, fmt.Errorf("invalid key type: %s, expected none or stream", keyType)
+			}
+		}
+
+		for _, key := range matched {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, false, fmt.Errorf("no stream keys found matching %v", patterns)
+	}
+	return keys, composite, nil
+}
+
+// groupKeys groups the keys by cluster slot, keeping their order, or returns them as a single group
+func groupKeys(keys []string, clustered bool) [][]string {
+	if !clustered {
+		return [][]string{keys}
+	}
+	groups := make([][]string, 0)
+	slotGroup := make(map[int]int)
+	for _, key := range keys {
+		slot := cluster.Slot(key)
+		idx, ok := slotGroup[slot]
+		if !ok {
+			idx = len(groups)
+			slotGroup[slot] = idx
+			groups = append(groups, nil)
+		}
+		groups[idx] = append(groups[idx], key)
+	}
+	return groups
+}
+
+// scanStreamKeys returns the stream keys matching the pattern using SCAN, on each master of a cluster
+func scanStreamKeys(client redis.Conn, pattern string) ([]string, error) {
+	clusterConn, ok := client.(*cluster.Conn)
+	if !ok {
+		return scanNodeStreamKeys(client, pattern)
+	}
+	keys := make([]string, 0)
+	err := clusterConn.ForEachMaster(func(_ string, node redis.Conn) error {
+		matched, err := scanNodeStreamKeys(node, pattern)
+		keys = append(keys, matched...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// scanNodeStreamKeys returns the stream keys of a single node matching the pattern
+func scanNodeStreamKeys(client redis.Conn, pattern string) ([]string, error) {
+	keys := make([]string, 0)
+	cursor := "0"
+	for {
+		resp, err := redis.Values(client.Do("SCAN", cursor, "MATCH", pattern, "TYPE", keyTypeStream, "COUNT", 1000))
+		if err != nil {
+			return nil, fmt.Errorf("error scanning keys matching pattern(%s): %w", pattern, err)
+		}
+		if len(resp) != 2 {
+			return nil, fmt.Errorf("invalid SCAN response, expected 2 elements, got %d", len(resp))
+		}
+		if cursor, err = redis.String(resp[0], nil); err != nil {
+			return nil, fmt.Errorf("invalid SCAN cursor: %w", err)
+		}
+		matched, err := redis.Strings(resp[1], nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SCAN keys: %w", err)
+		}
+		keys = append(keys, matched...)
+		if cursor == "0" {
+			return keys, nil
 		}
 	}
 }
 
-// toRecords parses the XREAD command's response and returns a slice of opencdc.Record
-func toRecords(resp []interface{}) ([]opencdc.Record, error) {
+// startID returns the id to start reading the key from when there is no stored position, the latest id is
+// resolved to the id of the last message of the stream, as the polling XREAD wouldn't return any message otherwise
+func startID(client redis.Conn, key, startFrom string) (string, error) {
+	switch startFrom {
+	case "":
+		return config.StreamIDEarliest, nil
+	case config.StreamIDLatest:
+		entries, err := redis.Values(client.Do("XREVRANGE", key, "+", "-", "COUNT", 1))
+		if err != nil {
+			return "", fmt.Errorf("error fetching last message of key(%s): %w", key, err)
+		}
+		if len(entries) == 0 {
+			return config.StreamIDEarliest, nil
+		}
+		id, _, err := parsePositionData(entries[0])
+		if err != nil {
+			return "", fmt.Errorf("error parsing last message of key(%s): %w", key, err)
+		}
+		return string(id), nil
+	default:
+		return startFrom, nil
+	}
+}
+
+// createGroup creates the consumer group on the stream key, starting at the passed id,
+// creating an empty stream if the key doesn't exist. An already existing group is left untouched.
+func createGroup(client redis.Conn, key, group, id string) error {
+	_, err := client.Do("XGROUP", "CREATE", key, group, id, "MKSTREAM")
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("error creating consumer group(%s) on key(%s): %w", group, key, err)
+	}
+	return nil
+}
+
+// toRecords parses the XREAD command's response and returns a slice of opencdc.Record,
+// along with the stream key each of them was read from
+func (i *StreamIterator) toRecords(resp []interface{}) ([]opencdc.Record, []string, error) {
 	records := make([]opencdc.Record, 0)
+	keys := make([]string, 0)
 	for _, iKey := range resp {
 		key, idList, err := parseKeyData(iKey)
 		if err != nil {
-			return nil, err
-		}
-
-		metadata := opencdc.Metadata{
-			"key": string(key),
+			return nil, nil, err
 		}
 
 		for _, iID := range idList {
+			// the pending messages deleted from the stream are returned without fields when read again,
+			// they can't be delivered anymore and are acknowledged so they don't stay pending forever
+			if idInfo, ok := iID.([]interface{}); ok && len(idInfo) > 1 && idInfo[1] == nil {
+				if err := i.ackDeleted(string(key), idInfo[0]); err != nil {
+					return records, keys, err
+				}
+				continue
+			}
 			position, fieldList, err := parsePositionData(iID)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			rMap, err := arrInterfaceToMap(fieldList)
 			if err != nil {
-				return records, fmt.Errorf("error converting the []interface{} to map: %w", err)
+				return records, keys, fmt.Errorf("error converting the []interface{} to map: %w", err)
 			}
-			payload, err := json.Marshal(rMap)
+			keys = append(keys, string(key))
+
+			if i.encoding == config.EncodingOpenCDC {
+				encoded, ok := rMap[config.EncodedRecordField]
+				if !ok {
+					return records, keys, fmt.Errorf("message(%s) has no %q field holding the record", position, config.EncodedRecordField)
+				}
+				rec, err := decodeRecord([]byte(encoded), position)
+				if err != nil {
+					return records, keys, fmt.Errorf("error decoding message(%s): %w", position, err)
+				}
+				records = append(records, rec)
+				continue
+			}
+			metadata := make(opencdc.Metadata)
+			recordKey, operation, err := i.mapFields(rMap, metadata)
+			if err != nil {
+				return records, keys, fmt.Errorf("error mapping the fields of message(%s): %w", position, err)
+			}
+			if recordKey == nil {
+				recordKey = opencdc.RawData(key)
+			}
+			payload, err := i.payload(rMap)
 			if err != nil {
-				return records, fmt.Errorf("error marshaling the map: %w", err)
+				return records, keys, err
 			}
 
+			// the key the message was read from can't be overwritten by the mapped metadata
+			metadata["key"] = string(key)
 			metadata.SetCreatedAt(getTimeFromPosition(string(position)))
 
-			records = append(records, sdk.Util.Source.NewRecordCreate(
-				position,
-				metadata,
-				opencdc.RawData(key),
-				opencdc.RawData(payload),
-			))
+			switch operation {
+			case opencdc.OperationUpdate:
+				records = append(records, sdk.Util.Source.NewRecordUpdate(position, metadata, recordKey, nil, payload))
+			case opencdc.OperationDelete:
+				records = append(records, sdk.Util.Source.NewRecordDelete(position, metadata, recordKey, payload))
+			default:
+				records = append(records, sdk.Util.Source.NewRecordCreate(position, metadata, recordKey, payload))
+			}
 		}
 	}
-	return records, nil
+	return records, keys, nil
+}
+
+// ackDeleted acknowledges the pending message which was deleted from the stream
+func (i *StreamIterator) ackDeleted(key string, id interface{}) error {
+	if i.group == "" {
+		return nil
+	}
+	if _, err := i.do("XACK", key, i.group, id); err != nil {
+		return fmt.Errorf("error acknowledging deleted message(%s) in group(%s): %w", id, i.group, err)
+	}
+	return nil
 }
 
 // parseKeyData parses the data for each key received in the XREAD response
@@ -16,12 +16,17 @@ package iterator
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/conduitio-labs/conduit-connector-redis/config"
 	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/gomodule/redigo/redis"
 	"github.com/rafaeljusto/redigomock"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/tomb.v2"
@@ -33,8 +38,11 @@ func TestNewStreamIterator(t *testing.T) {
 		pos            opencdc.Position
 		fn             func(conn *redigomock.Conn)
 		pollingPeriod  time.Duration
+		group          string
+		startFrom      string
 		err            error
 		expectedLastID string
+		pending        bool
 	}{
 		{
 			name:          "NewCDCIterator with lastModifiedTime=0",
@@ -54,6 +62,86 @@ func TestNewStreamIterator(t *testing.T) {
 			},
 			err:            nil,
 			expectedLastID: "dummy_id",
+		}, {
+			name:          "NewCDCIterator starting from id",
+			pos:           []byte(""),
+			pollingPeriod: time.Second,
+			startFrom:     "1652107432000-0",
+			fn: func(conn *redigomock.Conn) {
+				conn.Command("TYPE", "dummy_key").Expect("stream")
+			},
+			err:            nil,
+			expectedLastID: "1652107432000-0",
+		}, {
+			name:          "NewCDCIterator with position ignores start from",
+			pos:           []byte("dummy_id"),
+			pollingPeriod: time.Second,
+			startFrom:     "1652107432000-0",
+			fn: func(conn *redigomock.Conn) {
+				conn.Command("TYPE", "dummy_key").Expect("stream")
+			},
+			err:            nil,
+			expectedLastID: "dummy_id",
+		}, {
+			name:          "NewCDCIterator starting from latest",
+			pos:           []byte(""),
+			pollingPeriod: time.Second,
+			startFrom:     "$",
+			fn: func(conn *redigomock.Conn) {
+				conn.Command("TYPE", "dummy_key").Expect("stream")
+				conn.Command("XREVRANGE", "dummy_key", "+", "-", "COUNT", 1).Expect([]interface{}{
+					[]interface{}{[]byte("1652107432000-1"), []interface{}{[]byte("key"), []byte("value")}},
+				})
+			},
+			err:            nil,
+			expectedLastID: "1652107432000-1",
+		}, {
+			name:          "NewCDCIterator starting from latest of empty stream",
+			pos:           []byte(""),
+			pollingPeriod: time.Second,
+			startFrom:     "$",
+			fn: func(conn *redigomock.Conn) {
+				conn.Command("TYPE", "dummy_key").Expect("none")
+				conn.Command("XREVRANGE", "dummy_key", "+", "-", "COUNT", 1).Expect([]interface{}{})
+			},
+			err:            nil,
+			expectedLastID: "0-0",
+		}, {
+			name:          "NewCDCIterator with consumer group",
+			pos:           []byte("dummy_id"),
+			pollingPeriod: time.Second,
+			group:         "dummy_group",
+			fn: func(conn *redigomock.Conn) {
+				conn.Command("TYPE", "dummy_key").Expect("stream")
+				conn.Command("XGROUP", "CREATE", "dummy_key", "dummy_group", "dummy_id", "MKSTREAM").Expect("OK")
+			},
+			err:            nil,
+			expectedLastID: "dummy_id",
+			pending:        true,
+		}, {
+			name:          "NewCDCIterator with existing consumer group",
+			pos:           []byte(""),
+			pollingPeriod: time.Second,
+			group:         "dummy_group",
+			fn: func(conn *redigomock.Conn) {
+				conn.Command("TYPE", "dummy_key").Expect("stream")
+				conn.Command("XGROUP", "CREATE", "dummy_key", "dummy_group", "0-0", "MKSTREAM").
+					ExpectError(errors.New("BUSYGROUP Consumer Group name already exists"))
+			},
+			err:            nil,
+			expectedLastID: "0-0",
+			pending:        true,
+		}, {
+			name:          "NewCDCIterator with consumer group fails",
+			pos:           []byte(""),
+			pollingPeriod: time.Second,
+			group:         "dummy_group",
+			fn: func(conn *redigomock.Conn) {
+				conn.Command("TYPE", "dummy_key").Expect("stream")
+				conn.Command("XGROUP", "CREATE", "dummy_key", "dummy_group", "0-0", "MKSTREAM").
+					ExpectError(errors.New("dummy_error"))
+			},
+			err: errors.New("error creating consumer group(dummy_group) on key(dummy_key): dummy_error"),
 		},
 	}
 	for _, tt := range tests {
@@ -61,9 +149,16 @@ func TestNewStreamIterator(t *testing.T) {
 			key := "dummy_key"
 			client := redigomock.NewConn()
 			tt.fn(client)
-			res, err := NewStreamIterator(context.Background(), client, key, tt.pollingPeriod, tt.pos)
+			cfg := config.Config{
+				RedisKey:      key,
+				PollingPeriod: tt.pollingPeriod,
+				ConsumerGroup: tt.group,
+				ConsumerName:  "dummy_consumer",
+				StartFrom:     tt.startFrom,
+			}
+			res, err := NewStreamIterator(context.Background(), client, nil, nil, cfg, tt.pos)
 			if tt.err != nil {
-				assert.NotNil(t, err)
+				assert.EqualError(t, err, tt.err.Error())
 			} else {
 				assert.NotNil(t, res)
 				assert.NotNil(t, res.caches)
@@ -72,7 +167,11 @@ func TestNewStreamIterator(t *testing.T) {
 				assert.NotNil(t, res.ticker)
 				assert.Equal(t, tt.pollingPeriod, res.pollingInterval)
 				assert.Equal(t, client, res.client)
-				assert.Equal(t, tt.expectedLastID, res.lastID)
+				assert.Equal(t, tt.expectedLastID, res.lastIDs[key])
+				assert.False(t, res.composite)
+				if tt.pending {
+					assert.Equal(t, map[string]string{key: groupPendingID}, res.pendingIDs)
+				}
 				assert.True(t, res.tomb.Alive())
 				res.tomb.Kill(fmt.Errorf("stop"))
 			}
@@ -167,7 +266,7 @@ func TestStartIterator(t *testing.T) {
 	conn := redigomock.NewConn()
 	tmbWithCtx, _ := tomb.WithContext(ctx)
 	conn.Command("XREAD", "COUNT", 10, "STREAMS", key, "0-0").Expect([]interface{}{[]interface{}{[]byte(key), []interface{}{[]interface{}{[]byte("1652107432000-0"), []interface{}{[]byte("key"), []byte("value")}}}}})
-	cdc := &StreamIterator{key: key, caches: make(chan []opencdc.Record, 1), ticker: time.NewTicker(time.Millisecond), recordsPerCall: 10, lastID: "0-0", tomb: tmbWithCtx, client: conn}
+	cdc := &StreamIterator{keys: []string{key}, keyGroups: [][]string{{key}}, caches: make(chan []opencdc.Record, 1), ticker: time.NewTicker(time.Millisecond), recordsPerCall: 10, lastIDs: map[string]string{key: "0-0"}, tomb: tmbWithCtx, client: conn, mux: &sync.Mutex{}}
 	_ = cdc.startIterator(ctx)()
 	select {
 	case cache := <-cdc.caches:
@@ -186,6 +285,104 @@ func TestStartIterator(t *testing.T) {
 	}
 }
 
+func TestStartIterator_StructuredPayload(t *testing.T) {
+	key := "dummy_key"
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	conn := redigomock.NewConn()
+	tmbWithCtx, _ := tomb.WithContext(ctx)
+	fields := []interface{}{
+		[]byte("name"), []byte("jane"),
+		[]byte("age"), []byte("42"),
+		[]byte("active"), []byte("true"),
+		[]byte("address"), []byte(`{"city":"Berlin"}`),
+	}
+	conn.Command("XREAD", "COUNT", 10, "STREAMS", key, "0-0").Expect([]interface{}{[]interface{}{[]byte(key), []interface{}{[]interface{}{[]byte("1652107432000-0"), fields}}}})
+	cdc := &StreamIterator{
+		keys: []string{key}, keyGroups: [][]string{{key}}, caches: make(chan []opencdc.Record, 1), ticker: time.NewTicker(time.Millisecond), recordsPerCall: 10,
+		lastIDs: map[string]string{key: "0-0"}, tomb: tmbWithCtx, client: conn, mux: &sync.Mutex{},
+		payloadFormat: config.PayloadFormatStructured,
+		fieldTypes:    map[string]string{"age": config.FieldTypeInt, "active": config.FieldTypeBool, "address": config.FieldTypeJSON},
+	}
+	_ = cdc.startIterator(ctx)()
+	select {
+	case cache := <-cdc.caches:
+		cancel()
+		assert.Len(t, cache, 1)
+		assert.Equal(t, opencdc.StructuredData{
+			"name":    "jane",
+			"age":     int64(42),
+			"active":  true,
+			"address": map[string]interface{}{"city": "Berlin"},
+		}, cache[0].Payload.After)
+	case <-ctx.Done():
+		t.Error("no data received in cache channel")
+	}
+}
+
+func TestStreamIterator_ToRecordsMapping(t *testing.T) {
+	cdc := &StreamIterator{keyField: "id", operationField: "op", metadataPrefix: "meta."}
+	message := func(id string, fields ...string) interface{} {
+		values := make([]interface{}, 0, len(fields))
+		for _, f := range fields {
+			values = append(values, []byte(f))
+		}
+		return []interface{}{[]byte(id), values}
+	}
+	resp := []interface{}{[]interface{}{[]byte("users"), []interface{}{
+		message("1-0", "id", "user:1", "name", "jane", "meta.source", "billing", "meta.key", "other"),
+		message("2-0", "id", "user:1", "op", "update", "name", "john"),
+		message("3-0", "op", "delete", "name", "john"),
+	}}}
+
+	records, _, err := cdc.toRecords(resp)
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+
+	assert.Equal(t, opencdc.OperationCreate, records[0].Operation)
+	assert.Equal(t, opencdc.RawData("user:1"), records[0].Key)
+	assert.Equal(t, "billing", records[0].Metadata["source"])
+	assert.Equal(t, "users", records[0].Metadata["key"])
+	assert.Equal(t, opencdc.RawData(`{"name":"jane"}`), records[0].Payload.After)
+
+	assert.Equal(t, opencdc.OperationUpdate, records[1].Operation)
+	assert.Equal(t, opencdc.RawData(`{"name":"john"}`), records[1].Payload.After)
+
+	// the stream key is used when the key field isn't set
+	assert.Equal(t, opencdc.OperationDelete, records[2].Operation)
+	assert.Equal(t, opencdc.RawData("users"), records[2].Key)
+	assert.Equal(t, opencdc.RawData(`{"name":"john"}`), records[2].Payload.Before)
+
+	_, _, err = cdc.toRecords([]interface{}{[]interface{}{[]byte("users"), []interface{}{message("4-0", "op", "upsert")}}})
+	assert.EqualError(t, err, `error mapping the fields of message(4-0): field(op) contains unsupported operation "upsert", expected one of [create update delete]`)
+}
+
+func TestStreamIterator_ToRecordsOpenCDC(t *testing.T) {
+	cdc := &StreamIterator{encoding: config.EncodingOpenCDC}
+	encoded := `{"operation":"update","metadata":{"source":"billing"},"key":"dXNlcjox",` +
+		`"payload":{"before":null,"after":{"name":"jane"}}}`
+	resp := []interface{}{[]interface{}{[]byte("users"), []interface{}{
+		[]interface{}{[]byte("1-0"), []interface{}{[]byte("record"), []byte(encoded)}},
+	}}}
+
+	records, keys, err := cdc.toRecords(resp)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"users"}, keys)
+	assert.Equal(t, []opencdc.Record{{
+		Position:  opencdc.Position("1-0"),
+		Operation: opencdc.OperationUpdate,
+		Metadata:  opencdc.Metadata{"source": "billing"},
+		Key:       opencdc.RawData("user:1"),
+		Payload:   opencdc.Change{After: opencdc.StructuredData{"name": "jane"}},
+	}}, records)
+
+	resp = []interface{}{[]interface{}{[]byte("users"), []interface{}{
+		[]interface{}{[]byte("2-0"), []interface{}{[]byte("name"), []byte("jane")}},
+	}}}
+	_, _, err = cdc.toRecords(resp)
+	assert.EqualError(t, err, `message(2-0) has no "record" field holding the record`)
+}
+
 func TestStartIterator_Err(t *testing.T) {
 	key := "dummy_key"
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
@@ -193,7 +390,7 @@ func TestStartIterator_Err(t *testing.T) {
 	conn := redigomock.NewConn()
 	tmbWithCtx, _ := tomb.WithContext(ctx)
 	conn.Command("XREAD", "COUNT", 10, "STREAMS", key, "0-0").Expect([]interface{}{[]interface{}{[]byte(key), []interface{}{[]interface{}{[]byte("1652107432000-0"), []interface{}{[]byte("key")}}}}})
-	cdc := &StreamIterator{key: key, caches: make(chan []opencdc.Record, 1), ticker: time.NewTicker(time.Millisecond), recordsPerCall: 10, lastID: "0-0", tomb: tmbWithCtx, client: conn}
+	cdc := &StreamIterator{keys: []string{key}, keyGroups: [][]string{{key}}, caches: make(chan []opencdc.Record, 1), ticker: time.NewTicker(time.Millisecond), recordsPerCall: 10, lastIDs: map[string]string{key: "0-0"}, tomb: tmbWithCtx, client: conn, mux: &sync.Mutex{}}
 	err := cdc.startIterator(ctx)()
 	assert.EqualError(t, err, "error converting stream data to records: error converting the []interface{} to map: arrInterfaceToMap expects even number of values result, got 1")
 }
@@ -203,9 +400,503 @@ func TestStreamIterator_Stop(t *testing.T) {
 		tomb:   &tomb.Tomb{},
 		ticker: time.NewTicker(time.Second),
 		client: redigomock.NewConn(),
+		mux:    &sync.Mutex{},
 	}
 	err := cdc.Stop()
 	assert.Nil(t, err)
 	assert.Nil(t, cdc.client)
 	assert.False(t, cdc.tomb.Alive())
 }
+
+func TestStartIterator_ConsumerGroup(t *testing.T) {
+	key := "dummy_key"
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	conn := redigomock.NewConn()
+	tmbWithCtx, _ := tomb.WithContext(ctx)
+	// the pending messages are read first, followed by an empty list once all of them are delivered again
+	conn.Command("XREADGROUP", "GROUP", "dummy_group", "dummy_consumer", "COUNT", 10, "STREAMS", key, "0").
+		Expect([]interface{}{[]interface{}{[]byte(key), []interface{}{[]interface{}{[]byte("1652107432000-0"), []interface{}{[]byte("key"), []byte("value")}}}}})
+	conn.Command("XREADGROUP", "GROUP", "dummy_group", "dummy_consumer", "COUNT", 10, "STREAMS", key, "1652107432000-0").
+		Expect([]interface{}{[]interface{}{[]byte(key), []interface{}{}}})
+	conn.Command("XREADGROUP", "GROUP", "dummy_group", "dummy_consumer", "COUNT", 10, "STREAMS", key, ">").
+		Expect([]interface{}{[]interface{}{[]byte(key), []interface{}{[]interface{}{[]byte("1652107432001-0"), []interface{}{[]byte("key"), []byte("value")}}}}})
+	cdc := &StreamIterator{
+		keys:           []string{key},
+		keyGroups:      [][]string{{key}},
+		group:          "dummy_group",
+		consumer:       "dummy_consumer",
+		caches:         make(chan []opencdc.Record, 2),
+		ticker:         time.NewTicker(time.Millisecond),
+		recordsPerCall: 10,
+		lastIDs:        map[string]string{key: "0-0"},
+		pendingIDs:     map[string]string{key: groupPendingID},
+		tomb:           tmbWithCtx,
+		client:         conn,
+		mux:            &sync.Mutex{},
+	}
+	cdc.tomb.Go(cdc.startIterator(ctx))
+
+	for _, want := range []string{"1652107432000-0", "1652107432001-0"} {
+		select {
+		case cache := <-cdc.caches:
+			assert.Len(t, cache, 1)
+			assert.Equal(t, opencdc.Position(want), cache[0].Position)
+		case <-ctx.Done():
+			t.Fatal("no data received in cache channel")
+		}
+	}
+	cdc.tomb.Kill(errors.New("stop"))
+	_ = cdc.tomb.Wait()
+	assert.Empty(t, cdc.pendingIDs)
+}
+
+func TestStartIterator_ConsumerGroupDeletedPending(t *testing.T) {
+	key := "dummy_key"
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	conn := redigomock.NewConn()
+	tmbWithCtx, _ := tomb.WithContext(ctx)
+	// the pending message deleted from the stream is returned without fields, it is acknowledged
+	// and the pending messages following it are read again
+	conn.Command("XREADGROUP", "GROUP", "dummy_group", "dummy_consumer", "COUNT", 10, "STREAMS", key, "0").
+		Expect([]interface{}{[]interface{}{[]byte(key), []interface{}{[]interface{}{[]byte("1652107432000-0"), nil}}}}).
+		Expect([]interface{}{[]interface{}{[]byte(key), []interface{}{[]interface{}{[]byte("1652107432001-0"), []interface{}{[]byte("key"), []byte("value")}}}}})
+	ack := conn.Command("XACK", key, "dummy_group", []byte("1652107432000-0")).Expect(int64(1))
+	conn.Command("XREADGROUP", "GROUP", "dummy_group", "dummy_consumer", "COUNT", 10, "STREAMS", key, "1652107432001-0").
+		Expect([]interface{}{[]interface{}{[]byte(key), []interface{}{}}})
+	conn.Command("XREADGROUP", "GROUP", "dummy_group", "dummy_consumer", "COUNT", 10, "STREAMS", key, ">").
+		Expect([]interface{}{[]interface{}{[]byte(key), []interface{}{}}})
+	cdc := &StreamIterator{
+		keys:           []string{key},
+		keyGroups:      [][]string{{key}},
+		group:          "dummy_group",
+		consumer:       "dummy_consumer",
+		caches:         make(chan []opencdc.Record, 2),
+		ticker:         time.NewTicker(time.Millisecond),
+		recordsPerCall: 10,
+		lastIDs:        map[string]string{key: "0-0"},
+		pendingIDs:     map[string]string{key: groupPendingID},
+		tomb:           tmbWithCtx,
+		client:         conn,
+		mux:            &sync.Mutex{},
+	}
+	cdc.tomb.Go(cdc.startIterator(ctx))
+
+	select {
+	case cache := <-cdc.caches:
+		assert.Len(t, cache, 1)
+		assert.Equal(t, opencdc.Position("1652107432001-0"), cache[0].Position)
+	case <-ctx.Done():
+		t.Fatal("no data received in cache channel")
+	}
+	cdc.tomb.Kill(errors.New("stop"))
+	_ = cdc.tomb.Wait()
+	assert.Equal(t, 1, conn.Stats(ack))
+}
+
+func TestStreamIterator_Ack(t *testing.T) {
+	tests := []struct {
+		name  string
+		group string
+		fn    func(conn *redigomock.Conn)
+		err   error
+	}{
+		{
+			name: "no consumer group",
+			fn:   func(*redigomock.Conn) {},
+		}, {
+			name:  "ack in consumer group",
+			group: "dummy_group",
+			fn: func(conn *redigomock.Conn) {
+				conn.Command("XACK", "dummy_key", "dummy_group", "1652107432000-0").Expect(int64(1))
+			},
+		}, {
+			name:  "ack fails",
+			group: "dummy_group",
+			fn: func(conn *redigomock.Conn) {
+				conn.Command("XACK", "dummy_key", "dummy_group", "1652107432000-0").ExpectError(errors.New("dummy_error"))
+			},
+			err: errors.New("error acknowledging message(1652107432000-0) in group(dummy_group): dummy_error"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := redigomock.NewConn()
+			tt.fn(conn)
+			cdc := &StreamIterator{keys: []string{"dummy_key"}, keyGroups: [][]string{{"dummy_key"}}, group: tt.group, client: conn, mux: &sync.Mutex{}}
+			err := cdc.Ack(context.Background(), opencdc.Position("1652107432000-0"))
+			if tt.err != nil {
+				assert.EqualError(t, err, tt.err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestStreamIterator_Claim(t *testing.T) {
+	key := "dummy_key"
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	conn, err := redis.Dial("tcp", mr.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, id := range []string{"1652107432000-0", "1652107432001-0"} {
+		_, err = conn.Do("XADD", key, id, "key", id)
+		assert.NoError(t, err)
+	}
+	_, err = conn.Do("XGROUP", "CREATE", key, "dummy_group", "0")
+	assert.NoError(t, err)
+	// another consumer reads both messages and never acks them, the first one is delivered a second time
+	_, err = conn.Do("XREADGROUP", "GROUP", "dummy_group", "crashed", "COUNT", 10, "STREAMS", key, ">")
+	assert.NoError(t, err)
+	_, err = conn.Do("XCLAIM", key, "dummy_group", "crashed", 0, "1652107432000-0")
+	assert.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+
+	cdc := &StreamIterator{
+		keys:           []string{key},
+		keyGroups:      [][]string{{key}},
+		group:          "dummy_group",
+		consumer:       "dummy_consumer",
+		claimMinIdle:   time.Millisecond,
+		maxDeliveries:  2,
+		deadLetterKey:  "dead_letter",
+		claimCursors:   map[string]string{},
+		recordsPerCall: 10,
+		lastIDs:        map[string]string{key: "0-0"},
+		client:         conn,
+		mux:            &sync.Mutex{},
+	}
+	records, err := cdc.claim(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, opencdc.Position("1652107432001-0"), records[0].Position)
+	assert.Equal(t, opencdc.RawData(`{"key":"1652107432001-0"}`), records[0].Payload.After)
+
+	dead, err := redis.Values(conn.Do("XRANGE", "dead_letter", "-", "+"))
+	assert.NoError(t, err)
+	assert.Len(t, dead, 1)
+	pending, err := redis.Values(conn.Do("XPENDING", key, "dummy_group", "-", "+", 10))
+	assert.NoError(t, err)
+	assert.Len(t, pending, 1)
+}
+
+func TestNewStreamIterator_MultipleKeys(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	conn, err := redis.Dial("tcp", mr.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"orders:1", "orders:2", "users"} {
+		_, err = conn.Do("XADD", key, "1652107432000-0", "key", key)
+		assert.NoError(t, err)
+	}
+	_, err = conn.Do("SET", "orders:3", "not a stream")
+	assert.NoError(t, err)
+
+	pos, err := streamPosition{
+		Key:     "orders:1",
+		ID:      "1652107432000-0",
+		LastIDs: map[string]string{"orders:1": "1652107432000-0"},
+	}.toPosition()
+	assert.NoError(t, err)
+
+	cfg := config.Config{RedisKey: "orders:*, users", PollingPeriod: time.Millisecond}
+	res, err := NewStreamIterator(context.Background(), conn, nil, nil, cfg, pos)
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, res.Stop())
+	}()
+	assert.True(t, res.composite)
+	assert.ElementsMatch(t, []string{"orders:1", "orders:2", "users"}, res.keys)
+
+	// orders:1 is resumed after the stored position, the other keys are read from the start
+	got := make(map[string]streamPosition)
+	for len(got) < 2 {
+		rec, err := res.Next(context.Background())
+		assert.NoError(t, err)
+		var pos streamPosition
+		assert.NoError(t, json.Unmarshal(rec.Position, &pos))
+		assert.Equal(t, rec.Metadata["key"], pos.Key)
+		got[pos.Key] = pos
+	}
+	assert.NotContains(t, got, "orders:1")
+	assert.Equal(t, "1652107432000-0", got["users"].ID)
+	assert.Equal(t, "1652107432000-0", got["users"].LastIDs["users"])
+	assert.Equal(t, "1652107432000-0", got["users"].LastIDs["orders:1"])
+}
+
+func TestStreamIterator_BlockTimeout(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	conn, err := redis.Dial("tcp", mr.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockConn, err := redis.Dial("tcp", mr.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Config{RedisKey: "events", PollingPeriod: time.Hour, BlockTimeout: time.Hour}
+	_, err = NewStreamIterator(context.Background(), conn, nil, nil, cfg, nil)
+	assert.EqualError(t, err, "a dedicated client is required to read the stream with a block timeout")
+
+	res, err := NewStreamIterator(context.Background(), conn, blockConn, nil, cfg, nil)
+	assert.NoError(t, err)
+
+	// the message is read as soon as it is added, without waiting for the polling period
+	_, err = mr.XAdd("events", "1652107432000-0", []string{"key", "value"})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	rec, err := res.Next(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, opencdc.Position("1652107432000-0"), rec.Position)
+
+	// stop interrupts the blocked read instead of waiting for the block timeout
+	assert.NoError(t, res.Stop())
+	select {
+	case <-res.tomb.Dead():
+	case <-time.After(time.Second):
+		t.Fatal("iterator didn't stop")
+	}
+	assert.EqualError(t, res.tomb.Err(), "iterator stopped")
+}
+
+func TestStreamIterator_EndAt(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	conn, err := redis.Dial("tcp", mr.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"1-0", "2-0", "3-0", "4-0"} {
+		_, err := mr.XAdd("events", id, []string{"key", id})
+		assert.NoError(t, err)
+	}
+
+	// the messages after the position are read up to the latest message at the time of opening
+	cfg := config.Config{RedisKey: "events", PollingPeriod: time.Millisecond, EndAt: config.StreamIDLatest}
+	res, err := NewStreamIterator(context.Background(), conn, nil, nil, cfg, opencdc.Position("1-0"))
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, res.Stop())
+	}()
+	assert.Equal(t, map[string]string{"events": "4-0"}, res.endIDs)
+	_, err = mr.XAdd("events", "5-0", []string{"key", "5-0"})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var got []string
+	for {
+		rec, err := res.Next(ctx)
+		if err != nil {
+			assert.ErrorIs(t, err, ErrEndReached)
+			break
+		}
+		got = append(got, string(rec.Position))
+	}
+	assert.Equal(t, []string{"2-0", "3-0", "4-0"}, got)
+	assert.True(t, res.HasNext())
+	_, err = res.Next(ctx)
+	assert.ErrorIs(t, err, ErrEndReached)
+}
+
+func TestStreamIterator_GapPolicy(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	conn, err := redis.Dial("tcp", mr.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"1-0", "2-0", "3-0", "4-0"} {
+		_, err := mr.XAdd("events", id, []string{"key", id})
+		assert.NoError(t, err)
+	}
+	_, err = conn.Do("XTRIM", "events", "MAXLEN", 2)
+	assert.NoError(t, err)
+
+	// the message after the position was trimmed
+	cfg := config.Config{RedisKey: "events", PollingPeriod: time.Millisecond, GapPolicy: config.GapPolicyFail}
+	_, err = NewStreamIterator(context.Background(), conn, nil, nil, cfg, opencdc.Position("1-0"))
+	assert.EqualError(t, err, `messages of key(events) after id(1-0) were trimmed, first available id is "3-0"`)
+
+	cfg.GapPolicy = config.GapPolicyRecord
+	res, err := NewStreamIterator(context.Background(), conn, nil, nil, cfg, opencdc.Position("1-0"))
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	gap, err := res.Next(ctx)
+	assert.NoError(t, err)
+	// the gap record has its own position, after the last message read
+	want, err := streamPosition{Key: "events", ID: "1-0", LastIDs: map[string]string{"events": "1-0"}, Gap: true}.toPosition()
+	assert.NoError(t, err)
+	assert.Equal(t, want, gap.Position)
+	assert.Equal(t, "gap", gap.Metadata["type"])
+	assert.Equal(t, "1-0", gap.Metadata["gap.afterId"])
+	assert.Equal(t, "3-0", gap.Metadata["gap.beforeId"])
+	for _, want := range []string{"3-0", "4-0"} {
+		rec, err := res.Next(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, opencdc.Position(want), rec.Position)
+	}
+	assert.NoError(t, res.Stop())
+
+	// the gap isn't reported again when restarting from the gap record
+	cfg.GapPolicy = config.GapPolicyFail
+	conn, err = redis.Dial("tcp", mr.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted, err := NewStreamIterator(context.Background(), conn, nil, nil, cfg, gap.Position)
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, restarted.Stop())
+	}()
+	rec, err := restarted.Next(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, opencdc.Position("3-0"), rec.Position)
+}
+
+func TestStreamIterator_GapTrimmedAtPosition(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	conn, err := redis.Dial("tcp", mr.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"1-0", "1-1", "2-0"} {
+		_, err := mr.XAdd("events", id, []string{"key", id})
+		assert.NoError(t, err)
+	}
+	// the stream is trimmed right at the position, the first message left is the next one, without the deleted
+	// ids which aren't returned by the redis versions older than 7.0
+	_, err = conn.Do("XTRIM", "events", "MAXLEN", 2)
+	assert.NoError(t, err)
+
+	cfg := config.Config{RedisKey: "events", PollingPeriod: time.Millisecond, GapPolicy: config.GapPolicyFail}
+	res, err := NewStreamIterator(context.Background(), conn, nil, nil, cfg, opencdc.Position("1-0"))
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, res.Stop())
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	rec, err := res.Next(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, opencdc.Position("1-1"), rec.Position)
+}
+
+func TestStreamIterator_Cluster(t *testing.T) {
+	mr := miniredis.RunT(t)
+	for _, key := range []string{"{orders}:eu", "{orders}:us", "users:1"} {
+		_, err := mr.XAdd(key, "1-0", []string{"key", key})
+		assert.NoError(t, err)
+	}
+	// miniredis replies to CLUSTER SHARDS as a single node owning all the slots
+	cfg := config.Config{
+		RedisKey:         "{orders}:eu,{orders}:us,users:*",
+		PollingPeriod:    time.Millisecond,
+		ClusterAddresses: []string{mr.Addr()},
+	}
+	conn, err := cfg.Dial(context.Background())
+	assert.NoError(t, err)
+
+	blockCfg := cfg
+	blockCfg.BlockTimeout = time.Second
+	_, err = NewStreamIterator(context.Background(), conn, conn, nil, blockCfg, nil)
+	assert.EqualError(t, err, "reading the streams with a block timeout in a cluster requires the keys to be in the same slot, use a hash tag")
+
+	res, err := NewStreamIterator(context.Background(), conn, nil, nil, cfg, nil)
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, res.Stop())
+	}()
+	assert.Equal(t, [][]string{{"{orders}:eu", "{orders}:us"}, {"users:1"}}, res.keyGroups)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	keys := make([]string, 0, 3)
+	for range 3 {
+		rec, err := res.Next(ctx)
+		assert.NoError(t, err)
+		keys = append(keys, rec.Metadata["key"])
+	}
+	assert.ElementsMatch(t, []string{"{orders}:eu", "{orders}:us", "users:1"}, keys)
+}
+
+func TestParseStreamPosition(t *testing.T) {
+	keys := []string{"dummy_key", "other_key"}
+	tests := []struct {
+		name     string
+		position opencdc.Position
+		want     streamPosition
+		err      error
+	}{
+		{
+			name:     "empty position",
+			position: nil,
+			want:     streamPosition{},
+		}, {
+			name:     "message id",
+			position: opencdc.Position("1652107432000-0"),
+			want: streamPosition{
+				Key:     "dummy_key",
+				ID:      "1652107432000-0",
+				LastIDs: map[string]string{"dummy_key": "1652107432000-0"},
+			},
+		}, {
+			name:     "composite position",
+			position: opencdc.Position(`{"key":"other_key","id":"1-0","lastIds":{"dummy_key":"2-0","other_key":"1-0"}}`),
+			want: streamPosition{
+				Key:     "other_key",
+				ID:      "1-0",
+				LastIDs: map[string]string{"dummy_key": "2-0", "other_key": "1-0"},
+			},
+		}, {
+			name:     "invalid composite position",
+			position: opencdc.Position(`{"key":`),
+			err:      errors.New(`invalid position({"key":): unexpected end of JSON input`),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseStreamPosition(tt.position, keys)
+			if tt.err != nil {
+				assert.EqualError(t, err, tt.err.Error())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
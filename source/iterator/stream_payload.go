@@ -0,0 +1,108 @@
+// Copyright © 2022 Meroxa, Inc. & Gophers Lab Technologies Pvt. Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/conduitio-labs/conduit-connector-redis/config"
+	"github.com/conduitio/conduit-commons/opencdc"
+)
+
+// mapFields removes the fields mapped to the record key, the operation and the metadata from the message fields.
+// The metadata fields are added to metadata without the prefix. The key is nil when the key field isn't set,
+// in which case the stream key is used, and the operation is create when the operation field isn't set.
+func (i *StreamIterator) mapFields(fields map[string]string, metadata opencdc.Metadata) (opencdc.Data, opencdc.Operation, error) {
+	var key opencdc.Data
+	if value, ok := fields[i.keyField]; ok && i.keyField != "" {
+		key = opencdc.RawData(value)
+		delete(fields, i.keyField)
+	}
+
+	operation := opencdc.OperationCreate
+	if value, ok := fields[i.operationField]; ok && i.operationField != "" {
+		switch value {
+		case opencdc.OperationCreate.String():
+		case opencdc.OperationUpdate.String():
+			operation = opencdc.OperationUpdate
+		case opencdc.OperationDelete.String():
+			operation = opencdc.OperationDelete
+		default:
+			return nil, 0, fmt.Errorf("field(%s) contains unsupported operation %q, expected one of %v", i.operationField, value,
+				[]string{opencdc.OperationCreate.String(), opencdc.OperationUpdate.String(), opencdc.OperationDelete.String()})
+		}
+		delete(fields, i.operationField)
+	}
+
+	if i.metadataPrefix != "" {
+		for name, value := range fields {
+			if field, ok := strings.CutPrefix(name, i.metadataPrefix); ok {
+				metadata[field] = value
+				delete(fields, name)
+			}
+		}
+	}
+	return key, operation, nil
+}
+
+// payload returns the payload of the record holding the fields of a stream message, either the JSON of the fields
+// or, in the structured format, the fields coerced to their configured types
+func (i *StreamIterator) payload(fields map[string]string) (opencdc.Data, error) {
+	if i.payloadFormat != config.PayloadFormatStructured {
+		payload, err := json.Marshal(fields)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling the map: %w", err)
+		}
+		return opencdc.RawData(payload), nil
+	}
+
+	data := make(opencdc.StructuredData, len(fields))
+	for name, value := range fields {
+		fieldType, ok := i.fieldTypes[name]
+		if !ok {
+			data[name] = value
+			continue
+		}
+		coerced, err := coerceField(value, fieldType)
+		if err != nil {
+			return nil, fmt.Errorf("error coercing field(%s) to %s: %w", name, fieldType, err)
+		}
+		data[name] = coerced
+	}
+	return data, nil
+}
+
+// coerceField converts the string value of a stream message field to the type
+func coerceField(value, fieldType string) (interface{}, error) {
+	switch fieldType {
+	case config.FieldTypeInt:
+		return strconv.ParseInt(value, 10, 64)
+	case config.FieldTypeFloat:
+		return strconv.ParseFloat(value, 64)
+	case config.FieldTypeBool:
+		return strconv.ParseBool(value)
+	case config.FieldTypeJSON:
+		var v interface{}
+		if err := json.Unmarshal([]byte(value), &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	default:
+		return value, nil
+	}
+}
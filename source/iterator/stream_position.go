@@ -0,0 +1,60 @@
+// Copyright © 2022 Meroxa, Inc. & Gophers Lab Technologies Pvt. Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/conduitio/conduit-commons/opencdc"
+)
+
+// streamPosition is the position of a record read from one of multiple stream keys.
+// Along with the key and id of the message, it holds the id of the last message read from every key,
+// so that all the keys are resumed from the right message on restart.
+type streamPosition struct {
+	Key     string            `json:"key"`
+	ID      string            `json:"id"`
+	LastIDs map[string]string `json:"lastIds"`
+	// Gap is true for the position of a gap record, which is placed right after the message with the id
+	Gap bool `json:"gap,omitempty"`
+}
+
+// toPosition encodes the streamPosition as JSON
+func (p streamPosition) toPosition() (opencdc.Position, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling the position: %w", err)
+	}
+	return b, nil
+}
+
+// parseStreamPosition parses either a JSON encoded streamPosition or the plain message id used as position
+// when reading a single key, in which case the id belongs to the first of the keys
+func parseStreamPosition(position opencdc.Position, keys []string) (streamPosition, error) {
+	if len(position) == 0 {
+		return streamPosition{}, nil
+	}
+	if position[0] != '{' {
+		id := string(position)
+		return streamPosition{Key: keys[0], ID: id, LastIDs: map[string]string{keys[0]: id}}, nil
+	}
+
+	var pos streamPosition
+	if err := json.Unmarshal(position, &pos); err != nil {
+		return streamPosition{}, fmt.Errorf("invalid position(%s): %w", string(position), err)
+	}
+	return pos, nil
+}
@@ -0,0 +1,235 @@
+// Copyright © 2022 Meroxa, Inc. & Gophers Lab Technologies Pvt. Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/conduitio-labs/conduit-connector-redis/config"
+	"github.com/conduitio/conduit-commons/opencdc"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/gomodule/redigo/redis"
+	"gopkg.in/tomb.v2"
+)
+
+// ZSetIterator tails a sorted set by score, polling for the members with a score greater than the last one read,
+// members with the same score are read in lexicographical order, the same way redis orders them
+type ZSetIterator struct {
+	key    string
+	client redis.Conn
+	tomb   *tomb.Tomb
+	// reconnector re-establishes the connection after a connection loss, the members after the last one are polled again
+	reconnector reconnector
+	// last is the position of the last member read, nil until a member is read
+	last           *zsetPosition
+	recordsPerCall int
+	ticker         *time.Ticker
+	caches         chan []opencdc.Record
+	buffer         chan opencdc.Record
+}
+
+// zsetPosition is the score and the member of a record, encoded as "score:member"
+type zsetPosition struct {
+	score float64
+	// rawScore is the score as returned by redis, used as is in the queries to not lose precision
+	rawScore string
+	member   string
+}
+
+// NewZSetIterator creates a new instance of sorted set iterator and starts polling for new members
+// after the member in position, in a separate go routine. dial is used to reconnect after a connection loss.
+func NewZSetIterator(ctx context.Context, client redis.Conn, dial Dialer, cfg config.Config, position opencdc.Position) (*ZSetIterator, error) {
+	last, err := parseZSetPosition(position)
+	if err != nil {
+		return nil, err
+	}
+
+	// zset mode reads a single key, validated by the config
+	key := cfg.Keys()[0]
+	keyType, err := redis.String(client.Do("TYPE", key))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching type of key(%s): %w", key, err)
+	}
+	if keyType != keyTypeNone && keyType != keyTypeZSet {
+		return nil, fmt.Errorf("invalid key type: %s, expected none or %s", keyType, keyTypeZSet)
+	}
+
+	tmbWithCtx, _ := tomb.WithContext(ctx)
+	cdc := &ZSetIterator{
+		key:            key,
+		client:         client,
+		tomb:           tmbWithCtx,
+		last:           last,
+		reconnector:    newReconnector(dial, cfg),
+		recordsPerCall: 1000,
+		ticker:         time.NewTicker(cfg.PollingPeriod),
+		caches:         make(chan []opencdc.Record, 1),
+		buffer:         make(chan opencdc.Record, 1),
+	}
+
+	cdc.tomb.Go(cdc.startIterator(ctx))
+	cdc.tomb.Go(cdc.flush)
+
+	return cdc, nil
+}
+
+// HasNext returns whether there are any more records to be returned
+func (i *ZSetIterator) HasNext() bool {
+	return len(i.buffer) > 0 || !i.tomb.Alive() // if tomb is dead we return true so caller will fetch error with Next
+}
+
+// Next returns the next record in buffer and error in case there are no more records
+// and there was an error leading to tomb dying or context was cancelled
+func (i *ZSetIterator) Next(ctx context.Context) (opencdc.Record, error) {
+	select {
+	case rec := <-i.buffer:
+		return rec, nil
+	case <-i.tomb.Dying():
+		return opencdc.Record{}, i.tomb.Err()
+	case <-ctx.Done():
+		return opencdc.Record{}, ctx.Err()
+	}
+}
+
+// Ack is a no-op, the members are left in the sorted set
+func (i *ZSetIterator) Ack(context.Context, opencdc.Position) error {
+	return nil
+}
+
+// Stop stops the go routines, the client is closed by the polling go routine once it returns
+func (i *ZSetIterator) Stop() error {
+	i.ticker.Stop()
+	i.tomb.Kill(errors.New("iterator stopped"))
+	return nil
+}
+
+// startIterator is the go routine function used to poll the sorted set for new members at regular intervals,
+// the connection is re-established after a connection loss when enabled
+func (i *ZSetIterator) startIterator(ctx context.Context) func() error {
+	return func() error {
+		// the client is replaced when reconnecting
+		defer func() { _ = i.client.Close() }()
+		return pollRecords(i.tomb, func() <-chan time.Time { return i.ticker.C }, func() ([]opencdc.Record, error) {
+			records, err := i.poll()
+			if err == nil || !i.tomb.Alive() || !i.reconnector.canReconnect(err) {
+				return records, err
+			}
+			return nil, i.reconnect(ctx, err)
+		}, i.caches)
+	}
+}
+
+// reconnect replaces the connection after a connection loss, the next poll resumes after the last member read
+func (i *ZSetIterator) reconnect(ctx context.Context, cause error) error {
+	client, err := i.reconnector.reconnect(ctx, cause, i.tomb.Dying())
+	if err != nil {
+		return err
+	}
+	// the connection is broken already, the error closing it doesn't matter
+	_ = i.client.Close()
+	i.client = client
+	return nil
+}
+
+// poll returns the members after the last one read. The score of the last member is included in the range,
+// so that the members with the same score not read yet aren't skipped, and the members already read are dropped.
+func (i *ZSetIterator) poll() ([]opencdc.Record, error) {
+	minScore := "-inf"
+	if i.last != nil {
+		minScore = i.last.rawScore
+	}
+
+	for offset := 0; ; offset += i.recordsPerCall {
+		resp, err := redis.Strings(i.client.Do("ZRANGE", i.key, minScore, "+inf", "BYSCORE",
+			"LIMIT", offset, i.recordsPerCall, "WITHSCORES"))
+		if err != nil {
+			return nil, fmt.Errorf("error reading sorted set(%s): %w", i.key, err)
+		}
+
+		records := make([]opencdc.Record, 0, len(resp)/2)
+		for j := 0; j+1 < len(resp); j += 2 {
+			score, err := strconv.ParseFloat(resp[j+1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid score(%s) of member(%s): %w", resp[j+1], resp[j], err)
+			}
+			pos := &zsetPosition{score: score, rawScore: resp[j+1], member: resp[j]}
+			if i.last != nil && !pos.after(i.last) {
+				continue
+			}
+			records = append(records, i.toRecord(pos))
+			i.last = pos
+		}
+
+		// the whole batch can be dropped when more members than recordsPerCall have the score of the last one
+		if len(records) > 0 || len(resp)/2 < i.recordsPerCall {
+			return records, nil
+		}
+	}
+}
+
+// toRecord creates the record of the member
+func (i *ZSetIterator) toRecord(pos *zsetPosition) opencdc.Record {
+	metadata := opencdc.Metadata{
+		"type":  "zset",
+		"key":   i.key,
+		"score": pos.rawScore,
+	}
+	metadata.SetCreatedAt(time.Now())
+
+	return sdk.Util.Source.NewRecordCreate(
+		pos.toPosition(),
+		metadata,
+		opencdc.RawData(i.key),
+		opencdc.RawData(pos.member),
+	)
+}
+
+// flush is the go routine pushing the records polled by startIterator into the buffer read by Next
+func (i *ZSetIterator) flush() error {
+	return flushRecords(i.tomb, i.caches, i.buffer)
+}
+
+// after returns whether the member comes after the other one in the sorted set
+func (p *zsetPosition) after(other *zsetPosition) bool {
+	if p.score != other.score {
+		return p.score > other.score
+	}
+	return p.member > other.member
+}
+
+func (p *zsetPosition) toPosition() opencdc.Position {
+	return opencdc.Position(p.rawScore + ":" + p.member)
+}
+
+// parseZSetPosition parses the "score:member" position, the member can contain colons while the score can't
+func parseZSetPosition(position opencdc.Position) (*zsetPosition, error) {
+	if len(position) == 0 {
+		return nil, nil
+	}
+	rawScore, member, ok := strings.Cut(string(position), ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid position(%s), expected score:member", string(position))
+	}
+	score, err := strconv.ParseFloat(rawScore, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid position(%s): %w", string(position), err)
+	}
+	return &zsetPosition{score: score, rawScore: rawScore, member: member}, nil
+}
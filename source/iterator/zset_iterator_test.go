@@ -0,0 +1,160 @@
+// Copyright © 2022 Meroxa, Inc. & Gophers Lab Technologies Pvt. Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/conduitio-labs/conduit-connector-redis/config"
+	"github.com/conduitio/conduit-commons/opencdc"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZSetIterator(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	for member, score := range map[string]float64{"a": 1, "b": 2, "c": 2, "d": 3} {
+		_, err := mr.ZAdd("events", score, member)
+		assert.NoError(t, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tests := []struct {
+		name     string
+		position opencdc.Position
+		want     []string
+	}{
+		{
+			name:     "empty position",
+			position: nil,
+			want:     []string{"1:a", "2:b", "2:c", "3:d"},
+		}, {
+			name:     "resume after tie",
+			position: opencdc.Position("2:b"),
+			want:     []string{"2:c", "3:d"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := redis.Dial("tcp", mr.Addr())
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := NewZSetIterator(ctx, conn, nil, config.Config{
+				// the key is trimmed by the config
+				RedisKey:      " events ",
+				Mode:          config.ModeZSet,
+				PollingPeriod: 10 * time.Millisecond,
+			}, tt.position)
+			assert.NoError(t, err)
+			defer func() {
+				assert.NoError(t, res.Stop())
+			}()
+
+			got := make([]string, 0, len(tt.want))
+			for range tt.want {
+				rec, err := res.Next(ctx)
+				assert.NoError(t, err)
+				assert.Equal(t, fmt.Sprintf("%s:%s", rec.Metadata["score"], rec.Payload.After.Bytes()), string(rec.Position))
+				got = append(got, string(rec.Position))
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestZSetIterator_Ties(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	conn, err := redis.Dial("tcp", mr.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for _, member := range []string{"a", "b", "c", "d", "e"} {
+		_, err := mr.ZAdd("events", 1, member)
+		assert.NoError(t, err)
+	}
+
+	// more members than recordsPerCall share the score of the last one read
+	res := &ZSetIterator{
+		key:            "events",
+		client:         conn,
+		last:           &zsetPosition{score: 1, rawScore: "1", member: "c"},
+		recordsPerCall: 2,
+	}
+	for _, want := range []string{"1:d", "1:e"} {
+		records, err := res.poll()
+		assert.NoError(t, err)
+		if assert.Len(t, records, 1) {
+			assert.Equal(t, want, string(records[0].Position))
+		}
+	}
+
+	records, err := res.poll()
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestParseZSetPosition(t *testing.T) {
+	tests := []struct {
+		name     string
+		position opencdc.Position
+		want     *zsetPosition
+		err      string
+	}{
+		{
+			name:     "empty",
+			position: nil,
+			want:     nil,
+		}, {
+			name:     "member with colon",
+			position: opencdc.Position("1700000000000:user:1"),
+			want:     &zsetPosition{score: 1700000000000, rawScore: "1700000000000", member: "user:1"},
+		}, {
+			name:     "missing member",
+			position: opencdc.Position("1700000000000"),
+			err:      "invalid position(1700000000000), expected score:member",
+		}, {
+			name:     "invalid score",
+			position: opencdc.Position("abc:member"),
+			err:      `invalid position(abc:member): strconv.ParseFloat: parsing "abc": invalid syntax`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseZSetPosition(tt.position)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -14,6 +14,24 @@ type Iterator struct {
 	mock.Mock
 }
 
+// Ack provides a mock function with given fields: ctx, position
+func (_m *Iterator) Ack(ctx context.Context, position opencdc.Position) error {
+	ret := _m.Called(ctx, position)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Ack")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, opencdc.Position) error); ok {
+		r0 = rf(ctx, position)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // HasNext provides a mock function with no fields
 func (_m *Iterator) HasNext() bool {
 	ret := _m.Called()
@@ -18,6 +18,7 @@ package source
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/conduitio-labs/conduit-connector-redis/config"
@@ -33,11 +34,16 @@ type Source struct {
 
 	config   config.Config
 	iterator Iterator
+	// ended is true once the end of the stream was reached and logged
+	ended bool
+	// stopped is true once the iterator was stopped at the end of the stream, with onEnd stop
+	stopped bool
 }
 
 type Iterator interface {
 	HasNext() bool
 	Next(ctx context.Context) (opencdc.Record, error)
+	Ack(ctx context.Context, position opencdc.Position) error
 	Stop() error
 }
 
@@ -59,7 +65,7 @@ func (s *Source) Parameters() cconfig.Parameters {
 		},
 		config.KeyRedisKey: {
 			Default:     "",
-			Description: "Key name for connector to read",
+			Description: "Key name for connector to read, a comma separated list of keys or patterns to read multiple ones",
 			Validations: []cconfig.Validation{cconfig.ValidationRequired{}},
 		},
 		config.KeyDatabase: {
@@ -74,13 +80,145 @@ func (s *Source) Parameters() cconfig.Parameters {
 			Default:     "",
 			Description: "Username to the redis source",
 		},
+		config.KeyTLSEnabled: {
+			Default:     "false",
+			Description: "Whether to connect to redis over TLS",
+		},
+		config.KeyTLSCAFile: {
+			Default:     "",
+			Description: "Path to the PEM bundle of the CAs the server certificate is verified with, the system pool is used when empty",
+		},
+		config.KeyTLSCertFile: {
+			Default:     "",
+			Description: "Path to the PEM client certificate presented to the server for mutual TLS",
+		},
+		config.KeyTLSKeyFile: {
+			Default:     "",
+			Description: "Path to the PEM private key of the client certificate",
+		},
+		config.KeyTLSServerName: {
+			Default:     "",
+			Description: "Name the server certificate is verified against, the host is used when empty",
+		},
+		config.KeyTLSInsecureSkipVerify: {
+			Default:     "false",
+			Description: "Whether to skip the verification of the server certificate, only meant for testing",
+		},
+		config.KeySentinelAddresses: {
+			Default:     "",
+			Description: "Comma separated list of the host:port addresses of the sentinels the master is resolved with, instead of the host and port",
+		},
+		config.KeySentinelMasterName: {
+			Default:     "",
+			Description: "Name of the master monitored by the sentinels, required when redis.sentinel.addresses is set",
+		},
+		config.KeyClusterAddresses: {
+			Default:     "",
+			Description: "Comma separated list of the host:port addresses of the redis cluster nodes the topology is discovered from, instead of the host and port",
+		},
+		config.KeyURL: {
+			Default:     "",
+			Description: "Connection URL taking precedence over the host, port, database, username and password, either 'redis://', 'rediss://' for TLS or 'unix://' for a socket, with the 'db', 'dial_timeout', 'read_timeout' and 'write_timeout' query parameters",
+		},
 		config.KeyMode: {
 			Default:     "pubsub",
-			Description: "Sets the connector's operation mode. Available modes: ['pubsub', 'stream']",
+			Description: "Sets the connector's operation mode. Available modes: ['pubsub', 'shardpubsub', 'stream', 'keyspace', 'hash', 'list', 'zset']",
 		},
 		config.KeyPollingPeriod: {
 			Default:     "1s",
-			Description: "Time duration between successive data polling from streams",
+			Description: "Time duration between successive data polling from streams and sorted sets, and the BLMOVE timeout in list mode",
+		},
+		config.KeyConsumerGroup: {
+			Default:     "",
+			Description: "Consumer group used to read the stream with XREADGROUP, created if it doesn't exist",
+		},
+		config.KeyConsumerName: {
+			Default:     "",
+			Description: "Name of the consumer within the consumer group, required when consumerGroup is set",
+		},
+		config.KeyClaimMinIdle: {
+			Default:     "",
+			Description: "Minimum idle time of the pending messages of the consumer group to be claimed with XAUTOCLAIM",
+		},
+		config.KeyMaxDeliveries: {
+			Default:     "0",
+			Description: "Number of deliveries after which a pending message is moved to deadLetterKey",
+		},
+		config.KeyDeadLetterKey: {
+			Default:     "",
+			Description: "Stream key the messages exceeding maxDeliveries are added to",
+		},
+		config.KeyNotifyEvents: {
+			Default:     "",
+			Description: "Value of notify-keyspace-events set with CONFIG SET in keyspace and hash modes, e.g. 'KA'",
+		},
+		config.KeyBlockTimeout: {
+			Default:     "",
+			Description: "Time duration XREAD BLOCK waits for new messages in stream mode, instead of polling every pollingPeriod",
+		},
+		config.KeyStartFrom: {
+			Default:     "earliest",
+			Description: "Where to start reading the streams when there is no stored position: 'earliest', 'latest', a stream id or a RFC3339 timestamp",
+		},
+		config.KeyEndAt: {
+			Default:     "",
+			Description: "Id the streams are read up to with XRANGE: 'latest', a stream id or a RFC3339 timestamp, the streams are read forever when empty",
+		},
+		config.KeyOnEnd: {
+			Default:     "wait",
+			Description: "Behavior once the messages up to endAt were read, 'wait' to stay idle or 'stop' to stay idle with the connections closed",
+		},
+		config.KeyGapPolicy: {
+			Default:     "",
+			Description: "Handling of the stream messages trimmed after the position: 'fail', 'warn' or 'record' emitting a gap record, the gaps aren't checked when empty",
+		},
+		config.KeyPayloadFormat: {
+			Default:     "raw",
+			Description: "Format of the stream record payloads, 'raw' for the JSON of the message fields or 'structured' for structured data",
+		},
+		config.KeyFieldTypes: {
+			Default:     "",
+			Description: "Comma separated list of <field>:<type> the stream message fields are coerced to in the structured payload, the type being 'int', 'float', 'bool' or 'json'",
+		},
+		config.KeyKeyField: {
+			Default:     "",
+			Description: "Stream message field used as the record key instead of the stream key",
+		},
+		config.KeyOperationField: {
+			Default:     "",
+			Description: "Stream message field holding the operation of the record, 'create', 'update' or 'delete', the records are creates when empty",
+		},
+		config.KeyMetadataPrefix: {
+			Default:     "",
+			Description: "Prefix of the stream message fields added to the record metadata without the prefix instead of the payload",
+		},
+		config.KeyEncoding: {
+			Default:     "",
+			Description: "Set to 'opencdc' to decode the whole records written by the destination with the same encoding, in pubsub, shardpubsub and stream modes",
+		},
+		config.KeyBufferSize: {
+			Default:     "0",
+			Description: "Maximum number of received messages buffered in pubsub, shardpubsub, keyspace and hash modes, the buffer is unbounded when 0",
+		},
+		config.KeyOverflowPolicy: {
+			Default:     "block",
+			Description: "Behavior when a message is received while the buffer is full: 'block', 'dropOldest', 'dropNewest' or 'fail'",
+		},
+		config.KeyReconnectAttempts: {
+			Default:     "0",
+			Description: "Number of reconnection attempts after a connection loss, the connection loss stops the pipeline when 0 unless the master is resolved with the sentinels",
+		},
+		config.KeyReconnectMaxTime: {
+			Default:     "",
+			Description: "Maximum time spent reconnecting after a connection loss, formatted as a time.Duration string, unbounded when empty",
+		},
+		config.KeyReconnectBackoff: {
+			Default:     "100ms",
+			Description: "Delay before the first reconnection attempt, doubled after each attempt up to 30s, formatted as a time.Duration string",
+		},
+		config.KeyProcessingKey: {
+			Default:     "",
+			Description: "List the items are moved to until acked in list mode, defaults to '<redis.key>:processing'",
 		},
 	}
 }
@@ -97,33 +235,87 @@ func (s *Source) Configure(ctx context.Context, cfg cconfig.Config) error {
 }
 
 // Open prepare the plugin to start reading records from the given position
-func (s *Source) Open(ctx context.Context, position opencdc.Position) error {
-	address := s.config.Host + ":" + s.config.Port
-	dialOptions := make([]redis.DialOption, 0)
-	if s.config.Password != "" {
-		dialOptions = append(dialOptions, redis.DialPassword(s.config.Password))
-	}
-	if s.config.Username != "" {
-		dialOptions = append(dialOptions, redis.DialUsername(s.config.Username))
+func (s *Source) Open(ctx context.Context, position opencdc.Position) (err error) {
+	// the connections are owned by the iterator once it is created, they are closed if it can't be
+	var conns []redis.Conn
+	defer func() {
+		if err != nil {
+			for _, conn := range conns {
+				_ = conn.Close()
+			}
+		}
+	}()
+	dial := func() (redis.Conn, error) {
+		conn, err := s.dial(ctx)
+		if err != nil {
+			return nil, err
+		}
+		conns = append(conns, conn)
+		return conn, nil
 	}
-	dialOptions = append(dialOptions, redis.DialDatabase(s.config.Database))
 
-	redisClient, err := redis.DialContext(ctx, "tcp", address, dialOptions...)
+	redisClient, err := dial()
 	if err != nil {
-		return fmt.Errorf("failed to connect redis client: %w", err)
+		return err
 	}
 
 	switch s.config.Mode {
-	case config.ModePubSub:
-		s.iterator, err = iterator.NewPubSubIterator(ctx, redisClient, s.config.RedisKey)
+	case config.ModePubSub, config.ModeShardPubSub:
+		s.iterator, err = iterator.NewPubSubIterator(ctx, redisClient, s.dial, s.config, position)
 		if err != nil {
 			return fmt.Errorf("couldn't create a pubsub iterator: %w", err)
 		}
 	case config.ModeStream:
-		s.iterator, err = iterator.NewStreamIterator(ctx, redisClient, s.config.RedisKey, s.config.PollingPeriod, position)
+		var blockClient redis.Conn
+		if s.config.BlockTimeout > 0 {
+			// XREAD BLOCK holds the connection, Ack uses the other one
+			if blockClient, err = dial(); err != nil {
+				return err
+			}
+		}
+		s.iterator, err = iterator.NewStreamIterator(ctx, redisClient, blockClient, s.dial, s.config, position)
 		if err != nil {
 			return fmt.Errorf("couldn't create a stream iterator: %w", err)
 		}
+	case config.ModeKeyspace:
+		// the subscribed connection can't run other commands, the values are fetched with a second one
+		valueClient, err := dial()
+		if err != nil {
+			return err
+		}
+		s.iterator, err = iterator.NewKeyspaceIterator(ctx, redisClient, valueClient, s.dial, s.config)
+		if err != nil {
+			return fmt.Errorf("couldn't create a keyspace iterator: %w", err)
+		}
+	case config.ModeHash:
+		// the snapshot is taken with a third connection, as the values of the changes are fetched concurrently
+		valueClient, err := dial()
+		if err != nil {
+			return err
+		}
+		snapshotClient, err := dial()
+		if err != nil {
+			return err
+		}
+		s.iterator, err = iterator.NewHashIterator(ctx, redisClient, valueClient, snapshotClient, s.dial, s.config, position)
+		if err != nil {
+			return fmt.Errorf("couldn't create a hash iterator: %w", err)
+		}
+	case config.ModeList:
+		// BLMOVE blocks the connection, the processing list is updated with a second one
+		ackClient, err := dial()
+		if err != nil {
+			return err
+		}
+		s.iterator, err = iterator.NewListIterator(ctx, redisClient, ackClient, s.dial, s.config)
+		if err != nil {
+			return fmt.Errorf("couldn't create a list iterator: %w", err)
+		}
+	case config.ModeZSet:
+		s.iterator, err = iterator.NewZSetIterator(ctx, redisClient, s.dial, s.config, position)
+		if err != nil {
+			return fmt.Errorf("couldn't create a zset iterator: %w", err)
+		}
 	default:
 		return fmt.Errorf("invalid mode(%v) encountered", s.config.Mode)
 	}
@@ -131,31 +323,57 @@ func (s *Source) Open(ctx context.Context, position opencdc.Position) error {
 	return nil
 }
 
+// dial creates a new connection to redis
+func (s *Source) dial(ctx context.Context) (redis.Conn, error) {
+	return s.config.Dial(ctx)
+}
+
 // Read gets the next object
 func (s *Source) Read(ctx context.Context) (opencdc.Record, error) {
-	if !s.iterator.HasNext() {
+	if s.stopped || !s.iterator.HasNext() {
 		return opencdc.Record{}, sdk.ErrBackoffRetry
 	}
 	rec, err := s.iterator.Next(ctx)
+	if errors.Is(err, iterator.ErrEndReached) {
+		return opencdc.Record{}, s.end(ctx)
+	}
 	if err != nil {
 		return opencdc.Record{}, fmt.Errorf("error fetching next record: %w", err)
 	}
 	return rec, nil
 }
 
+// end is called once the end of the stream was reached, the backfill is done and the source stays idle until the
+// pipeline is stopped. With onEnd stop, the iterator is stopped as well, releasing its connections, the records
+// read can still be acked as endAt can't be used with a consumer group.
+func (s *Source) end(ctx context.Context) error {
+	if s.ended {
+		return sdk.ErrBackoffRetry
+	}
+	s.ended = true
+	sdk.Logger(ctx).Info().Msg("reached the end of the stream, no more records will be read")
+	if s.config.OnEnd == config.OnEndStop {
+		s.stopped = true
+		if err := s.iterator.Stop(); err != nil {
+			return fmt.Errorf("error stopping the iterator at the end of the stream: %w", err)
+		}
+	}
+	return sdk.ErrBackoffRetry
+}
+
 // Ack is called by the conduit server after the record has been successfully processed by all destination connectors
 func (s *Source) Ack(ctx context.Context, position opencdc.Position) error {
 	sdk.Logger(ctx).Debug().
 		Str("position", string(position)).
 		Str("mode", string(s.config.Mode)).
 		Msg("position ack received")
-	return nil
+	return s.iterator.Ack(ctx, position)
 }
 
 // Teardown is called by the conduit server to stop the source connector
 // all the cleanup should be done in this function
 func (s *Source) Teardown(_ context.Context) error {
-	if s.iterator != nil {
+	if s.iterator != nil && !s.stopped {
 		err := s.iterator.Stop()
 		if err != nil {
 			return err
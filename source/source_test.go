@@ -23,6 +23,7 @@ import (
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/conduitio-labs/conduit-connector-redis/config"
+	"github.com/conduitio-labs/conduit-connector-redis/source/iterator"
 	"github.com/conduitio-labs/conduit-connector-redis/source/mocks"
 	"github.com/conduitio/conduit-commons/opencdc"
 	sdk "github.com/conduitio/conduit-connector-sdk"
@@ -92,6 +93,7 @@ func TestOpen(t *testing.T) {
 			err:  nil,
 			source: Source{
 				config: config.Config{
+					RedisKey:      "dummy_key",
 					Mode:          config.ModeStream,
 					PollingPeriod: time.Second,
 				},
@@ -126,6 +128,25 @@ func TestOpen(t *testing.T) {
 	}
 }
 
+func TestOpenErrClosesConnections(t *testing.T) {
+	mr := miniredis.RunT(t)
+	// the processing list can't be read, once both connections of list mode are dialed
+	assert.NoError(t, mr.Set("dummy_key:processing", "value"))
+	s := new(Source)
+	s.config.Host = mr.Host()
+	s.config.Port = mr.Port()
+	s.config.Mode = config.ModeList
+	s.config.RedisKey = "dummy_key"
+	s.config.ProcessingKey = "dummy_key:processing"
+	s.config.PollingPeriod = time.Millisecond
+
+	err := s.Open(context.Background(), opencdc.Position{})
+	assert.ErrorContains(t, err, "couldn't create a list iterator")
+	assert.Eventually(t, func() bool {
+		return mr.CurrentConnectionCount() == 0
+	}, time.Second, time.Millisecond)
+}
+
 func TestOpenWithUserAuth(t *testing.T) {
 	mr, err := miniredis.Run()
 	assert.NoError(t, err)
@@ -135,6 +156,7 @@ func TestOpenWithUserAuth(t *testing.T) {
 	s.config.Host = mr.Host()
 	s.config.Port = mr.Port()
 	s.config.Mode = config.ModeStream
+	s.config.RedisKey = "dummy_key"
 	s.config.Username = "dummy_user"
 	s.config.Password = "dummy_password"
 	s.config.PollingPeriod = time.Millisecond
@@ -143,6 +165,7 @@ func TestOpenWithUserAuth(t *testing.T) {
 }
 
 func TestRead(t *testing.T) {
+	mockErr := errors.New("mock error")
 	tests := []struct {
 		name     string
 		response opencdc.Record
@@ -164,12 +187,26 @@ func TestRead(t *testing.T) {
 		{
 			name:     "records",
 			response: opencdc.Record{},
-			err:      errors.New("mock error"),
+			err:      mockErr,
+			source: Source{
+				iterator: func() Iterator {
+					m := &mocks.Iterator{}
+					m.On("HasNext", mock.Anything).Return(true)
+					m.On("Next", mock.Anything).Return(opencdc.Record{}, mockErr)
+					return m
+				}(),
+			},
+		},
+		{
+			name:     "end reached waiting",
+			response: opencdc.Record{},
+			err:      sdk.ErrBackoffRetry,
 			source: Source{
+				config: config.Config{EndAt: "$", OnEnd: config.OnEndWait},
 				iterator: func() Iterator {
 					m := &mocks.Iterator{}
 					m.On("HasNext", mock.Anything).Return(true)
-					m.On("Next", mock.Anything).Return(opencdc.Record{}, errors.New("mock error"))
+					m.On("Next", mock.Anything).Return(opencdc.Record{}, iterator.ErrEndReached)
 					return m
 				}(),
 			},
@@ -192,7 +229,7 @@ func TestRead(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			res, err := tt.source.Read(context.Background())
 			if tt.err != nil {
-				assert.NotNil(t, err, res)
+				assert.ErrorIs(t, err, tt.err)
 			} else {
 				assert.NotNil(t, res)
 				assert.Equal(t, res, tt.response)
@@ -201,6 +238,24 @@ func TestRead(t *testing.T) {
 	}
 }
 
+func TestReadEndStop(t *testing.T) {
+	m := &mocks.Iterator{}
+	m.On("HasNext", mock.Anything).Return(true).Once()
+	m.On("Next", mock.Anything).Return(opencdc.Record{}, iterator.ErrEndReached).Once()
+	m.On("Stop").Return(nil).Once()
+	m.On("Ack", mock.Anything, opencdc.Position("1-0")).Return(nil).Once()
+	s := Source{config: config.Config{EndAt: "$", OnEnd: config.OnEndStop}, iterator: m}
+
+	// the iterator is stopped without failing the pipeline
+	_, err := s.Read(context.Background())
+	assert.ErrorIs(t, err, sdk.ErrBackoffRetry)
+	_, err = s.Read(context.Background())
+	assert.ErrorIs(t, err, sdk.ErrBackoffRetry)
+	assert.NoError(t, s.Ack(context.Background(), opencdc.Position("1-0")))
+	assert.NoError(t, s.Teardown(context.Background()))
+	m.AssertExpectations(t)
+}
+
 func TestTeardown(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -240,20 +295,29 @@ func TestTeardown(t *testing.T) {
 }
 
 func TestAck(t *testing.T) {
-	var s Source
-	test := struct {
+	tests := []struct {
 		name string
 		err  error
 	}{
-		name: "ack",
-		err:  nil,
+		{
+			name: "ack",
+			err:  nil,
+		}, {
+			name: "ack fails",
+			err:  errors.New("mock error"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mocks.Iterator{}
+			m.On("Ack", mock.Anything, opencdc.Position("1652107432000-0")).Return(tt.err)
+			s := Source{iterator: m}
+			err := s.Ack(context.Background(), opencdc.Position("1652107432000-0"))
+			if tt.err != nil {
+				assert.EqualError(t, err, tt.err.Error())
+			} else {
+				assert.Nil(t, err)
+			}
+		})
 	}
-	t.Run(test.name, func(t *testing.T) {
-		err := s.Ack(context.Background(), opencdc.Position{})
-		if test.err != nil {
-			assert.NotNil(t, test.err, err)
-		} else {
-			assert.Nil(t, err)
-		}
-	})
 }